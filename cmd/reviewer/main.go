@@ -10,12 +10,18 @@ import (
 	"time"
 
 	"github.com/Caritas-Team/reviewer/internal/check"
+	"github.com/Caritas-Team/reviewer/internal/clientip"
 	"github.com/Caritas-Team/reviewer/internal/config"
 	"github.com/Caritas-Team/reviewer/internal/handler"
 	"github.com/Caritas-Team/reviewer/internal/logger"
-	"github.com/Caritas-Team/reviewer/internal/memcached"
 	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/storage"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/export"
 	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/idempotency"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/Caritas-Team/reviewer/internal/usecase/report"
 	"github.com/Caritas-Team/reviewer/internal/usecase/user"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
@@ -60,17 +66,76 @@ func main() {
 	rootCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// Кэш
-	cache, err := memcached.NewCache(rootCtx, cfg)
+	// Хранилища операций и rate limiter выбираются в конфиге независимо,
+	// при одинаковой настройке используется одно подключение
+	storages := storage.NewFactory(cfg)
+
+	cache, err := storages.Cache(rootCtx, cfg.Files.Storage)
 	if err != nil {
 		log.Error("cache initialization failed", "err", err)
 		return
 	}
 
-	rateLimiter := user.NewRateLimiter(cache, cfg)
-	rateLimiterMiddleware := handler.NewRateLimiterMiddleware(rateLimiter)
+	rateLimitCache, err := storages.Cache(rootCtx, cfg.RateLimiter.Storage)
+	if err != nil {
+		log.Error("rate limiter storage initialization failed", "err", err)
+		_ = storages.Close()
+		return
+	}
+
+	// Все обращения к хранилищам попадают в метрики и трейсы
+	cache = storage.Instrument(cache, storages.Kind(cfg.Files.Storage))
+	rateLimitCache = storage.Instrument(rateLimitCache, storages.Kind(cfg.RateLimiter.Storage))
+
+	clientIPResolver, err := clientip.NewResolver(cfg)
+	if err != nil {
+		log.Error("client ip resolver initialization failed", "err", err)
+		_ = storages.Close()
+		return
+	}
+
+	rateLimitPolicies, err := user.NewPolicies(rateLimitCache, cfg)
+	if err != nil {
+		log.Error("rate limiter policies initialization failed", "err", err)
+		_ = storages.Close()
+		return
+	}
+	rateLimiterMiddleware, err := handler.NewRateLimiterMiddleware(rateLimitPolicies, log)
+	if err != nil {
+		log.Error("rate limiter policies initialization failed", "err", err)
+		_ = storages.Close()
+		return
+	}
+
+	csvExporter, err := export.NewCSVExporter(cfg)
+	if err != nil {
+		log.Error("csv exporter initialization failed", "err", err)
+		_ = storages.Close()
+		return
+	}
+
+	// Слоты одновременных загрузок и обработок общие для всех экземпляров
+	concurrencyLimiter := user.NewConcurrencyLimiter(rateLimitCache, cfg)
+	concurrencyLimiter.Start(rootCtx)
+	concurrencyMiddleware := handler.NewConcurrencyMiddleware(concurrencyLimiter, log, cfg)
 
-	fileCleaner := file.NewFileCleaner(log, cache)
+	// Операции общие для загрузки, обработки, выдачи результата и очистки
+	operations := operation.NewStore(cache, cfg)
+
+	fileCleaner := file.NewFileCleaner(log, operations)
+	fileStorage := file.NewStorage()
+
+	// Асинхронная обработка операций
+	extractor := file.NewExtractor(log, fileStorage, cache, cfg)
+	comparisonService := comparison.NewService(operations, extractor, comparison.NewComparator(cfg))
+	chartBuilder := report.NewChartBuilder(cfg)
+	pipeline := report.NewPipeline(log, fileStorage, operations, extractor, comparisonService, report.NewPDFRenderer(chartBuilder))
+	scheduler := file.NewScheduler(log, operations, pipeline, concurrencyLimiter, cfg)
+	scheduler.Start(rootCtx)
+
+	idempotencyStore := idempotency.NewStore(cache, cfg)
+	adminHandler := handler.NewAdminHandler(storages, log, cfg)
+	fileHandler := handler.NewFileHandler(fileStorage, operations, scheduler, idempotencyStore, comparisonService, csvExporter, chartBuilder, log, cfg)
 
 	go func() {
 		ticker := time.NewTicker(1 * time.Minute)
@@ -96,6 +161,16 @@ func main() {
 	// Эндпоинт для readiness check
 	mux.HandleFunc("/ready", check.ReadinessCheckHandler(checker))
 
+	// Загрузка файлов
+	mux.Handle("POST /upload", concurrencyMiddleware.Handler(http.HandlerFunc(fileHandler.Upload)))
+
+	// Статус и результат операции
+	mux.HandleFunc("GET /get", fileHandler.Get)
+	mux.HandleFunc("GET /get/chart", fileHandler.Chart)
+
+	// Служебные эндпоинты
+	mux.HandleFunc("POST /admin/cache/{family}/bump", adminHandler.BumpNamespace)
+
 	// Метрики
 	metrics.InitMetricsOn(mux)
 
@@ -110,6 +185,7 @@ func main() {
 
 	h = rateLimiterMiddleware.Handler(h)
 	h = handler.LoggingMiddleware(log, h)
+	h = handler.ClientIPMiddleware(clientIPResolver, h)
 
 	h = otelhttp.NewHandler(h, "http-server")
 
@@ -140,7 +216,7 @@ func main() {
 	case err := <-errCh:
 		if err != nil {
 			log.Error("server failed", "err", err)
-			_ = cache.Close()
+			_ = storages.Close()
 			return
 		}
 	}
@@ -157,7 +233,10 @@ func main() {
 		log.Info("http server shutdown complete")
 	}
 
-	if err := cache.Close(); err != nil {
+	scheduler.Wait()
+	log.Info("scheduler stopped")
+
+	if err := storages.Close(); err != nil {
 		log.Error("cache close error", "err", err)
 	} else {
 		log.Info("cache closed")
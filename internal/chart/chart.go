@@ -0,0 +1,304 @@
+package chart
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoData = errors.New("chart has no data points")
+
+// Размер по умолчанию, в пикселях
+const (
+	defaultWidth  = 800
+	defaultHeight = 450
+)
+
+const dateLayout = "02.01.2006"
+
+// Палитра серий по умолчанию
+var palette = []color.NRGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+}
+
+var (
+	colorBackground = color.NRGBA{255, 255, 255, 255}
+	colorGrid       = color.NRGBA{225, 225, 225, 255}
+	colorAxis       = color.NRGBA{90, 90, 90, 255}
+	colorText       = color.NRGBA{40, 40, 40, 255}
+	colorBand       = color.NRGBA{44, 160, 44, 40}
+	colorBandText   = color.NRGBA{30, 110, 30, 255}
+)
+
+// Point — значение в момент времени
+type Point struct {
+	X time.Time
+	Y float64
+}
+
+// Series — линия на графике. Нулевой Color заменяется цветом из палитры.
+type Series struct {
+	Name   string
+	Points []Point
+	Color  color.NRGBA
+}
+
+// Band — референсная полоса значений, например диапазон нормы
+type Band struct {
+	Label string
+	From  float64
+	To    float64
+	Color color.NRGBA
+}
+
+// Chart — описание графика динамики показателей
+type Chart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Width  int
+	Height int
+	Series []Series
+	Bands  []Band
+}
+
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas — примитивы, которые одинаково реализуют SVG и PNG
+type canvas interface {
+	fillRect(x, y, w, h float64, c color.NRGBA)
+	line(x1, y1, x2, y2, width float64, c color.NRGBA)
+	polyline(points [][2]float64, width float64, c color.NRGBA)
+	circle(x, y, r float64, c color.NRGBA)
+	text(x, y float64, s string, size float64, a anchor, c color.NRGBA)
+}
+
+// Размеры шрифтов, в пикселях
+const (
+	titleSize = 16.0
+	labelSize = 12.0
+	tickSize  = 11.0
+)
+
+type layout struct {
+	width, height            float64
+	plotX, plotY             float64
+	plotW, plotH             float64
+	minX, maxX               time.Time
+	minY, maxY, stepY        float64
+	xTicks                   []time.Time
+	yTickLabelWidth, legendY float64
+	legendX                  []float64
+}
+
+func (l *layout) x(t time.Time) float64 {
+	span := l.maxX.Sub(l.minX).Seconds()
+	return l.plotX + l.plotW*t.Sub(l.minX).Seconds()/span
+}
+
+func (l *layout) y(v float64) float64 {
+	return l.plotY + l.plotH - l.plotH*(v-l.minY)/(l.maxY-l.minY)
+}
+
+func (c *Chart) normalize() error {
+	if c.Width <= 0 {
+		c.Width = defaultWidth
+	}
+	if c.Height <= 0 {
+		c.Height = defaultHeight
+	}
+
+	points := 0
+	for i := range c.Series {
+		if c.Series[i].Color == (color.NRGBA{}) {
+			c.Series[i].Color = palette[i%len(palette)]
+		}
+		slices.SortFunc(c.Series[i].Points, func(a, b Point) int { return a.X.Compare(b.X) })
+		points += len(c.Series[i].Points)
+	}
+	for i := range c.Bands {
+		if c.Bands[i].Color == (color.NRGBA{}) {
+			c.Bands[i].Color = colorBand
+		}
+		if c.Bands[i].From > c.Bands[i].To {
+			c.Bands[i].From, c.Bands[i].To = c.Bands[i].To, c.Bands[i].From
+		}
+	}
+
+	if points == 0 {
+		return ErrNoData
+	}
+	return nil
+}
+
+func newLayout(c *Chart, measure func(s string, size float64) float64) *layout {
+	l := &layout{width: float64(c.Width), height: float64(c.Height)}
+
+	var dates []time.Time
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			dates = append(dates, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	for _, b := range c.Bands {
+		minY, maxY = math.Min(minY, b.From), math.Max(maxY, b.To)
+	}
+
+	l.minY, l.maxY, l.stepY = niceRange(minY, maxY, 5)
+
+	slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	dates = slices.CompactFunc(dates, func(a, b time.Time) bool { return a.Equal(b) })
+	l.minX, l.maxX = dates[0], dates[len(dates)-1]
+	if !l.maxX.After(l.minX) {
+		l.minX = l.minX.Add(-24 * time.Hour)
+		l.maxX = l.maxX.Add(24 * time.Hour)
+	}
+
+	// Подписываем не больше восьми дат, чтобы они не накладывались
+	step := int(math.Ceil(float64(len(dates)) / 8))
+	for i := 0; i < len(dates); i += step {
+		l.xTicks = append(l.xTicks, dates[i])
+	}
+
+	for v := l.minY; v <= l.maxY+l.stepY/2; v += l.stepY {
+		l.yTickLabelWidth = math.Max(l.yTickLabelWidth, measure(formatNumber(v), tickSize))
+	}
+
+	top := 16.0
+	if c.Title != "" {
+		top += titleSize + 10
+	}
+	if c.YLabel != "" {
+		top += labelSize + 8
+	}
+
+	bottom := 16 + tickSize + 8
+	if c.XLabel != "" {
+		bottom += labelSize + 8
+	}
+	if len(c.Series) > 1 {
+		bottom += labelSize + 10
+	}
+
+	// Справа оставляем место под подписи полос и последнюю дату
+	right := 40.0
+	for _, b := range c.Bands {
+		right = math.Max(right, measure(b.Label, tickSize)+16)
+	}
+
+	l.plotX = 16 + l.yTickLabelWidth + 8
+	l.plotY = top
+	l.plotW = math.Max(l.width-l.plotX-right, 10)
+	l.plotH = math.Max(l.height-top-bottom, 10)
+	l.legendY = l.height - 16 - labelSize/2
+
+	if len(c.Series) > 1 {
+		x := l.plotX
+		for _, s := range c.Series {
+			l.legendX = append(l.legendX, x)
+			x += 16 + measure(s.Name, labelSize) + 24
+		}
+	}
+
+	return l
+}
+
+// plot рисует график на canvas, общий для всех форматов
+func plot(c *Chart, l *layout, cv canvas) {
+	cv.fillRect(0, 0, l.width, l.height, colorBackground)
+
+	y := 16.0
+	if c.Title != "" {
+		cv.text(l.plotX, y+titleSize/2, c.Title, titleSize, anchorStart, colorText)
+		y += titleSize + 10
+	}
+	if c.YLabel != "" {
+		cv.text(16, y+labelSize/2, c.YLabel, labelSize, anchorStart, colorText)
+	}
+
+	for _, b := range c.Bands {
+		top, bottom := l.y(b.To), l.y(b.From)
+		cv.fillRect(l.plotX, top, l.plotW, bottom-top, b.Color)
+		if b.Label != "" {
+			cv.text(l.plotX+l.plotW+6, (top+bottom)/2, b.Label, tickSize, anchorStart, colorBandText)
+		}
+	}
+
+	for v := l.minY; v <= l.maxY+l.stepY/2; v += l.stepY {
+		gy := l.y(v)
+		cv.line(l.plotX, gy, l.plotX+l.plotW, gy, 1, colorGrid)
+		cv.text(l.plotX-8, gy, formatNumber(v), tickSize, anchorEnd, colorText)
+	}
+
+	tickY := l.plotY + l.plotH + 8 + tickSize/2
+	for _, t := range l.xTicks {
+		tx := l.x(t)
+		cv.line(tx, l.plotY+l.plotH, tx, l.plotY+l.plotH+4, 1, colorAxis)
+		cv.text(tx, tickY, t.Format(dateLayout), tickSize, anchorMiddle, colorText)
+	}
+
+	cv.line(l.plotX, l.plotY, l.plotX, l.plotY+l.plotH, 1, colorAxis)
+	cv.line(l.plotX, l.plotY+l.plotH, l.plotX+l.plotW, l.plotY+l.plotH, 1, colorAxis)
+
+	if c.XLabel != "" {
+		cv.text(l.plotX+l.plotW/2, tickY+tickSize/2+8+labelSize/2, c.XLabel, labelSize, anchorMiddle, colorText)
+	}
+
+	for _, s := range c.Series {
+		points := make([][2]float64, 0, len(s.Points))
+		for _, p := range s.Points {
+			points = append(points, [2]float64{l.x(p.X), l.y(p.Y)})
+		}
+		if len(points) > 1 {
+			cv.polyline(points, 2.5, s.Color)
+		}
+		for _, p := range points {
+			cv.circle(p[0], p[1], 4, s.Color)
+		}
+	}
+
+	for i, x := range l.legendX {
+		cv.fillRect(x, l.legendY-5, 10, 10, c.Series[i].Color)
+		cv.text(x+16, l.legendY, c.Series[i].Name, labelSize, anchorStart, colorText)
+	}
+}
+
+// niceRange расширяет диапазон до «круглых» границ с шагом 1, 2 или 5 × 10^n
+func niceRange(minV, maxV float64, ticks int) (float64, float64, float64) {
+	if minV == maxV {
+		minV, maxV = minV-1, maxV+1
+	}
+
+	raw := (maxV - minV) / float64(ticks)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude * 10
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			step = m * magnitude
+			break
+		}
+	}
+
+	return math.Floor(minV/step) * step, math.Ceil(maxV/step) * step, step
+}
+
+func formatNumber(v float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64), ".", ",")
+}
@@ -0,0 +1,88 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testChart() Chart {
+	march := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	return Chart{
+		Title:  "Речь <устная> & письменная",
+		XLabel: "Дата диагностики",
+		YLabel: "Значение",
+		Width:  400,
+		Height: 300,
+		Series: []Series{
+			{Name: "Речь", Points: []Point{{X: may, Y: 12}, {X: march, Y: 10}}},
+			{Name: "Моторика", Points: []Point{{X: march, Y: 4}, {X: may, Y: 3}}},
+		},
+		Bands: []Band{{Label: "Норма", From: 12, To: 8}},
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderSVG(&buf, testChart()); err != nil {
+		t.Fatalf("ошибка рендеринга: %v", err)
+	}
+
+	// Документ должен разбираться как XML, несмотря на спецсимволы в заголовке
+	decoder := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("некорректный SVG: %v", err)
+			}
+			break
+		}
+	}
+
+	svg := buf.String()
+	for _, want := range []string{"&lt;устная&gt; &amp;", "Моторика", "Норма", "01.03.2025", "<polyline"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("в SVG нет %q", want)
+		}
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderPNG(&buf, testChart()); err != nil {
+		t.Fatalf("ошибка рендеринга: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("некорректный PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 300 {
+		t.Errorf("ожидался размер 400x300, получил %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestRender_NoData(t *testing.T) {
+	c := Chart{Series: []Series{{Name: "Речь"}}}
+
+	if err := RenderSVG(&bytes.Buffer{}, c); !errors.Is(err, ErrNoData) {
+		t.Errorf("SVG: ожидалась ErrNoData, получил %v", err)
+	}
+	if err := RenderPNG(&bytes.Buffer{}, c); !errors.Is(err, ErrNoData) {
+		t.Errorf("PNG: ожидалась ErrNoData, получил %v", err)
+	}
+}
+
+func TestNiceRange(t *testing.T) {
+	lo, hi, step := niceRange(3, 12, 5)
+	if lo != 2 || hi != 12 || step != 2 {
+		t.Errorf("ожидалось 2..12 с шагом 2, получил %v..%v с шагом %v", lo, hi, step)
+	}
+}
@@ -0,0 +1,206 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Шрифт Go покрывает кириллицу и не требует системных шрифтов
+var parsedFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+// RenderPNG пишет график в формате PNG
+func RenderPNG(w io.Writer, c Chart) error {
+	if err := c.normalize(); err != nil {
+		return err
+	}
+
+	cv, err := newPNGCanvas(c.Width, c.Height)
+	if err != nil {
+		return err
+	}
+	defer cv.close()
+
+	plot(&c, newLayout(&c, cv.measure), cv)
+
+	if err = png.Encode(w, cv.img); err != nil {
+		return fmt.Errorf("error encoding png chart: %w", err)
+	}
+	return nil
+}
+
+// measureText возвращает ширину строки в пикселях для заданного кегля
+func measureText(s string, size float64) float64 {
+	cv, err := newPNGCanvas(1, 1)
+	if err != nil {
+		// Грубая оценка, если шрифт недоступен
+		return float64(len([]rune(s))) * size * 0.6
+	}
+	defer cv.close()
+	return cv.measure(s, size)
+}
+
+type pngCanvas struct {
+	img   *image.RGBA
+	font  *opentype.Font
+	faces map[float64]font.Face
+}
+
+func newPNGCanvas(width, height int) (*pngCanvas, error) {
+	f, err := parsedFont()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing chart font: %w", err)
+	}
+
+	return &pngCanvas{
+		img:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		font:  f,
+		faces: make(map[float64]font.Face),
+	}, nil
+}
+
+func (p *pngCanvas) close() {
+	for _, face := range p.faces {
+		face.Close()
+	}
+}
+
+// face кэширует начертания в пределах одного рендера: font.Face не потокобезопасен
+func (p *pngCanvas) face(size float64) font.Face {
+	if face, ok := p.faces[size]; ok {
+		return face
+	}
+
+	face, err := opentype.NewFace(p.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil
+	}
+	p.faces[size] = face
+	return face
+}
+
+func (p *pngCanvas) measure(s string, size float64) float64 {
+	face := p.face(size)
+	if face == nil {
+		return float64(len([]rune(s))) * size * 0.6
+	}
+	return fixedToFloat(font.MeasureString(face, s))
+}
+
+func (p *pngCanvas) fillRect(x, y, w, h float64, c color.NRGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(p.img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+func (p *pngCanvas) line(x1, y1, x2, y2, width float64, c color.NRGBA) {
+	// Сетку и оси рисуем прямоугольниками, чтобы линии оставались чёткими
+	switch {
+	case y1 == y2:
+		p.fillRect(math.Min(x1, x2), math.Round(y1-width/2), math.Abs(x2-x1), math.Max(width, 1), c)
+	case x1 == x2:
+		p.fillRect(math.Round(x1-width/2), math.Min(y1, y2), math.Max(width, 1), math.Abs(y2-y1), c)
+	default:
+		p.polyline([][2]float64{{x1, y1}, {x2, y2}}, width, c)
+	}
+}
+
+func (p *pngCanvas) polyline(points [][2]float64, width float64, c color.NRGBA) {
+	z := p.rasterizer()
+	half := width / 2
+
+	for i := 1; i < len(points); i++ {
+		x1, y1 := points[i-1][0], points[i-1][1]
+		x2, y2 := points[i][0], points[i][1]
+		length := math.Hypot(x2-x1, y2-y1)
+		if length == 0 {
+			continue
+		}
+
+		nx, ny := -(y2-y1)/length*half, (x2-x1)/length*half
+		z.MoveTo(float32(x1+nx), float32(y1+ny))
+		z.LineTo(float32(x2+nx), float32(y2+ny))
+		z.LineTo(float32(x2-nx), float32(y2-ny))
+		z.LineTo(float32(x1-nx), float32(y1-ny))
+		z.ClosePath()
+	}
+
+	// Скругляем стыки отрезков
+	for _, pt := range points {
+		addCircle(z, pt[0], pt[1], half)
+	}
+
+	p.fill(z, c)
+}
+
+func (p *pngCanvas) circle(x, y, r float64, c color.NRGBA) {
+	z := p.rasterizer()
+	addCircle(z, x, y, r)
+	p.fill(z, c)
+}
+
+func (p *pngCanvas) text(x, y float64, s string, size float64, a anchor, c color.NRGBA) {
+	face := p.face(size)
+	if face == nil {
+		return
+	}
+
+	d := &font.Drawer{Dst: p.img, Src: image.NewUniform(c), Face: face}
+	switch a {
+	case anchorMiddle:
+		x -= fixedToFloat(d.MeasureString(s)) / 2
+	case anchorEnd:
+		x -= fixedToFloat(d.MeasureString(s))
+	}
+
+	// y — середина строки, как dominant-baseline="central" в SVG
+	m := face.Metrics()
+	baseline := y + (fixedToFloat(m.Ascent)-fixedToFloat(m.Descent))/2
+	d.Dot = fixed.Point26_6{X: floatToFixed(x), Y: floatToFixed(baseline)}
+	d.DrawString(s)
+}
+
+func (p *pngCanvas) rasterizer() *vector.Rasterizer {
+	b := p.img.Bounds()
+	return vector.NewRasterizer(b.Dx(), b.Dy())
+}
+
+func (p *pngCanvas) fill(z *vector.Rasterizer, c color.NRGBA) {
+	z.Draw(p.img, p.img.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+// addCircle добавляет окружность многоугольником. Обход совпадает с обходом
+// отрезков в polyline, иначе перекрытия взаимно вычитаются.
+func addCircle(z *vector.Rasterizer, x, y, r float64) {
+	const segments = 24
+	for i := 0; i <= segments; i++ {
+		angle := -2 * math.Pi * float64(i) / segments
+		px, py := float32(x+r*math.Cos(angle)), float32(y+r*math.Sin(angle))
+		if i == 0 {
+			z.MoveTo(px, py)
+			continue
+		}
+		z.LineTo(px, py)
+	}
+	z.ClosePath()
+}
+
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+func floatToFixed(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * 64))
+}
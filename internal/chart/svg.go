@@ -0,0 +1,96 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+const svgFontFamily = "Go, Helvetica, Arial, sans-serif"
+
+// RenderSVG пишет график в формате SVG
+func RenderSVG(w io.Writer, c Chart) error {
+	if err := c.normalize(); err != nil {
+		return err
+	}
+
+	cv := &svgCanvas{}
+	l := newLayout(&c, measureText)
+
+	fmt.Fprintf(&cv.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s">`+"\n",
+		c.Width, c.Height, c.Width, c.Height, svgFontFamily)
+	plot(&c, l, cv)
+	cv.buf.WriteString("</svg>\n")
+
+	if _, err := w.Write(cv.buf.Bytes()); err != nil {
+		return fmt.Errorf("error writing svg chart: %w", err)
+	}
+	return nil
+}
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func (s *svgCanvas) fillRect(x, y, w, h float64, c color.NRGBA) {
+	fmt.Fprintf(&s.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, svgColor(c))
+}
+
+func (s *svgCanvas) line(x1, y1, x2, y2, width float64, c color.NRGBA) {
+	fmt.Fprintf(&s.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.1f"/>`+"\n",
+		x1, y1, x2, y2, svgColor(c), width)
+}
+
+func (s *svgCanvas) polyline(points [][2]float64, width float64, c color.NRGBA) {
+	coords := make([]string, 0, len(points))
+	for _, p := range points {
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", p[0], p[1]))
+	}
+	fmt.Fprintf(&s.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%.1f" stroke-linejoin="round" stroke-linecap="round"/>`+"\n",
+		strings.Join(coords, " "), svgColor(c), width)
+}
+
+func (s *svgCanvas) circle(x, y, r float64, c color.NRGBA) {
+	fmt.Fprintf(&s.buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, r, svgColor(c))
+}
+
+func (s *svgCanvas) text(x, y float64, text string, size float64, a anchor, c color.NRGBA) {
+	anchors := [...]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+
+	fmt.Fprintf(&s.buf, `<text x="%.1f" y="%.1f" font-size="%.0f" text-anchor="%s" dominant-baseline="central" fill="%s">`,
+		x, y, size, anchors[a], svgColor(c))
+	escapeXML(&s.buf, text)
+	s.buf.WriteString("</text>\n")
+}
+
+func svgColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", c.R, c.G, c.B, float64(c.A)/255)
+}
+
+func escapeXML(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '&':
+			buf.WriteString("&amp;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\'':
+			buf.WriteString("&apos;")
+		default:
+			// Управляющие символы запрещены в XML 1.0
+			if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -24,8 +24,8 @@ func JSONResponse(w http.ResponseWriter, statusCode int, status string) {
 	}
 }
 
-// HealthCheckHandler — обработка health-check запроса с проверкой memcached и таймингом
-func HealthCheckHandler(cache *memcached.Cache, log *logger.Logger, maxResponseTime time.Duration) http.HandlerFunc {
+// HealthCheckHandler — обработка health-check запроса с проверкой кэша и таймингом
+func HealthCheckHandler(cache memcached.CacheInterface, log *logger.Logger, maxResponseTime time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		defer func() {
@@ -36,13 +36,7 @@ func HealthCheckHandler(cache *memcached.Cache, log *logger.Logger, maxResponseT
 				return
 			}
 
-			// Проверка доступности memcached
-			if err := cache.IsEnabled(); err != nil {
-				log.Error("Ошибка проверки активности кэша:", err)
-				JSONResponse(w, http.StatusInternalServerError, "NOT OK")
-				return
-			}
-
+			// Проверка доступности кэша
 			if err := cache.Ping(); err != nil {
 				log.Error("Ошибка проверки доступности кэша:", err)
 				JSONResponse(w, http.StatusInternalServerError, "NOT OK")
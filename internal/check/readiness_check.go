@@ -1,6 +1,7 @@
 package check
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/Caritas-Team/reviewer/internal/handler"
@@ -11,13 +12,13 @@ import (
 
 // ReadinessChecker проверяет состояние приложения
 type ReadinessChecker struct {
-	cache       *memcached.Cache
+	cache       memcached.CacheInterface
 	rateLimiter *handler.RateLimiterMiddleware
 	log         *logger.Logger
 }
 
 // Конструктор ReadinessChecker
-func NewReadinessChecker(cache *memcached.Cache, rateLimiter *handler.RateLimiterMiddleware, log *logger.Logger) *ReadinessChecker {
+func NewReadinessChecker(cache memcached.CacheInterface, rateLimiter *handler.RateLimiterMiddleware, log *logger.Logger) *ReadinessChecker {
 	return &ReadinessChecker{
 		cache:       cache,
 		rateLimiter: rateLimiter,
@@ -28,12 +29,7 @@ func NewReadinessChecker(cache *memcached.Cache, rateLimiter *handler.RateLimite
 // Проверка готовности
 func (rc *ReadinessChecker) IsReady() bool {
 
-	// Проверка memcached
-	if err := rc.cache.IsEnabled(); err != nil {
-		rc.log.Error("Ошибка проверки активности кэша:", err)
-		return false
-	}
-
+	// Проверка кэша
 	if err := rc.cache.Ping(); err != nil {
 		rc.log.Error("Ошибка проверки доступности кэша:", err)
 		return false
@@ -83,13 +79,36 @@ func processingStub() bool {
 	return true
 }
 
+// CircuitState возвращает состояние предохранителя кэша, если он есть
+func (rc *ReadinessChecker) CircuitState() (string, bool) {
+	state, ok := memcached.CircuitStateOf(rc.cache)
+	if !ok {
+		return "", false
+	}
+	return state.String(), true
+}
+
+type readinessResponse struct {
+	Message        string `json:"message"`
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+}
+
 // Обработчик readiness check
 func ReadinessCheckHandler(checker *ReadinessChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if checker.IsReady() {
-			JSONResponse(w, http.StatusOK, "READY")
-		} else {
-			JSONResponse(w, http.StatusServiceUnavailable, "NOT READY")
+		response := readinessResponse{Message: "READY"}
+		statusCode := http.StatusOK
+		if !checker.IsReady() {
+			response.Message = "NOT READY"
+			statusCode = http.StatusServiceUnavailable
+		}
+		// Состояние берём после проверки: Ping мог замкнуть или разомкнуть предохранитель
+		response.CircuitBreaker, _ = checker.CircuitState()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			checker.log.Error("cannot write readiness response", "err", err)
 		}
 	}
 }
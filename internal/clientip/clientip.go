@@ -0,0 +1,182 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+// Resolver определяет IP клиента. Заголовки прокси учитываются только
+// если их прислал доверенный прокси, иначе клиент мог бы подставить
+// любой адрес и обойти rate limiter.
+type Resolver struct {
+	trusted    []netip.Prefix
+	forwarded  bool
+	xClientIP  bool
+	ipv6Prefix int
+}
+
+func NewResolver(cfg config.Config) (*Resolver, error) {
+	r := &Resolver{
+		forwarded:  cfg.ClientIP.Forwarded,
+		xClientIP:  cfg.ClientIP.XClientIP,
+		ipv6Prefix: cfg.ClientIP.IPv6Prefix,
+	}
+
+	if r.ipv6Prefix < 0 || r.ipv6Prefix > 128 {
+		return nil, fmt.Errorf("error in client_ip config: invalid ipv6_prefix %d", r.ipv6Prefix)
+	}
+
+	for _, proxy := range cfg.ClientIP.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("error in client_ip config: invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+
+	return r, nil
+}
+
+// parsePrefix принимает CIDR или отдельный адрес
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Resolve возвращает IP клиента запроса. IPv6 при настроенном ipv6_prefix
+// возвращается подсетью, например 2001:db8::/64.
+func (r *Resolver) Resolve(req *http.Request) string {
+	host := req.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	peer, ok := parseNode(host)
+	if !ok {
+		return host
+	}
+	return r.normalize(r.client(req, peer))
+}
+
+// client проходит цепочку прокси справа налево: каждый доверенный прокси
+// добавил адрес того, кто к нему подключился. Первый недоверенный адрес — клиент.
+func (r *Resolver) client(req *http.Request, peer netip.Addr) netip.Addr {
+	if !r.isTrusted(peer) {
+		return peer
+	}
+
+	// X-Client-IP выставляет сам доверенный прокси перед сервисом
+	if r.xClientIP {
+		if addr, ok := parseNode(req.Header.Get("X-Client-IP")); ok {
+			return addr
+		}
+	}
+
+	var hops []string
+	if r.forwarded && len(req.Header.Values("Forwarded")) > 0 {
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	} else {
+		hops = splitList(req.Header.Values("X-Forwarded-For"))
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseNode(hops[i])
+		if !ok {
+			// Дальше цепочке верить нельзя, клиент — последний известный узел
+			break
+		}
+		client = addr
+		if !r.isTrusted(addr) {
+			break
+		}
+	}
+	return client
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize группирует IPv6 по подсети: у одного клиента обычно целая /64
+func (r *Resolver) normalize(addr netip.Addr) string {
+	if addr.Is6() && r.ipv6Prefix > 0 && r.ipv6Prefix < 128 {
+		prefix, err := addr.Prefix(r.ipv6Prefix)
+		if err == nil {
+			return prefix.String()
+		}
+	}
+	return addr.String()
+}
+
+// parseNode разбирает адрес узла: 192.0.2.1, 192.0.2.1:80, [2001:db8::1]:80
+// или 2001:db8::1. Зона IPv6 отбрасывается, IPv4 в IPv6 приводится к IPv4.
+func parseNode(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		addrPort, perr := netip.ParseAddrPort(s)
+		if perr != nil {
+			return netip.Addr{}, false
+		}
+		addr = addrPort.Addr()
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// splitList объединяет повторяющиеся заголовки и делит значения по запятым
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// forwardedFor достаёт узлы for= из заголовков Forwarded (RFC 7239).
+// Элемент без for= даёт пустой узел, который обрывает цепочку.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(key, "for") {
+				node = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, node)
+	}
+	return hops
+}
@@ -0,0 +1,155 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func newTestResolver(t *testing.T, cfg config.ClientIP) *Resolver {
+	t.Helper()
+
+	if cfg.TrustedProxies == nil {
+		cfg.TrustedProxies = []string{"10.0.0.0/8", "2001:db8:ffff::/48", "192.0.2.10"}
+	}
+	r, err := NewResolver(config.Config{ClientIP: cfg})
+	if err != nil {
+		t.Fatalf("ошибка создания resolver: %v", err)
+	}
+	return r
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ClientIP
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "прямое подключение",
+			remote: "203.0.113.5:1234",
+			want:   "203.0.113.5",
+		},
+		{
+			name:    "заголовок от недоверенного клиента игнорируется",
+			remote:  "203.0.113.5:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:    "203.0.113.5",
+		},
+		{
+			name:    "цепочка через доверенные прокси",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, 10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "подделанное начало цепочки не учитывается",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "повторяющиеся заголовки",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1", "198.51.100.7, 10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "мусор в цепочке",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"garbage, 10.0.0.2"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "все узлы доверенные",
+			remote:  "192.0.2.10:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "Forwarded",
+			cfg:     config.ClientIP{Forwarded: true},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"Forwarded": {`for=1.1.1.1, for="[2001:db8:1:2::5]:4711";proto=https, for=10.0.0.2`}},
+			want:    "2001:db8:1:2::5",
+		},
+		{
+			name:    "Forwarded выключен",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "X-Client-IP от доверенного прокси",
+			cfg:     config.ClientIP{XClientIP: true},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Client-IP": {"198.51.100.9"}, "X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "X-Client-IP от клиента игнорируется",
+			cfg:     config.ClientIP{XClientIP: true},
+			remote:  "203.0.113.5:1234",
+			headers: map[string][]string{"X-Client-IP": {"198.51.100.9"}},
+			want:    "203.0.113.5",
+		},
+		{
+			name:   "IPv4 в IPv6",
+			remote: "[::ffff:203.0.113.5]:1234",
+			want:   "203.0.113.5",
+		},
+		{
+			name:   "группировка IPv6",
+			cfg:    config.ClientIP{IPv6Prefix: 64},
+			remote: "[2001:db8:1:2:aaaa::1]:1234",
+			want:   "2001:db8:1:2::/64",
+		},
+		{
+			name:    "доверенный IPv6 прокси",
+			cfg:     config.ClientIP{IPv6Prefix: 64},
+			remote:  "[2001:db8:ffff::1]:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.5"}},
+			want:    "203.0.113.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestResolver(t, tt.cfg)
+
+			req := httptest.NewRequest("GET", "/get", nil)
+			req.RemoteAddr = tt.remote
+			for name, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+
+			if got := r.Resolve(req); got != tt.want {
+				t.Errorf("ожидался %s, получил %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.ClientIP
+	}{
+		{"некорректный CIDR", config.ClientIP{TrustedProxies: []string{"10.0.0.0/33"}}},
+		{"некорректный адрес", config.ClientIP{TrustedProxies: []string{"proxy"}}},
+		{"некорректный префикс IPv6", config.ClientIP{IPv6Prefix: 129}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewResolver(config.Config{ClientIP: tt.cfg}); err == nil {
+				t.Errorf("ожидалась ошибка конфига")
+			}
+		})
+	}
+}
@@ -20,6 +20,14 @@ func (s Server) Addr() string                { return fmt.Sprintf("%s:%d", s.Hos
 func (s Server) ReadTimeout() time.Duration  { return time.Duration(s.ReadTimeoutSec) * time.Second }
 func (s Server) WriteTimeout() time.Duration { return time.Duration(s.WriteTimeoutSec) * time.Second }
 
+// ClientIP — определение IP клиента за доверенными прокси
+type ClientIP struct {
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // CIDR или отдельные адреса
+	Forwarded      bool     `mapstructure:"forwarded"`       // читать RFC 7239 Forwarded
+	XClientIP      bool     `mapstructure:"x_client_ip"`     // читать X-Client-IP
+	IPv6Prefix     int      `mapstructure:"ipv6_prefix"`     // 0 — полный адрес
+}
+
 type CORS struct {
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 	AllowedMethods []string `mapstructure:"allowed_methods"`
@@ -27,17 +35,109 @@ type CORS struct {
 }
 
 type RateLimiter struct {
-	Enabled           bool   `mapstructure:"enabled"`
-	RequestsPerWindow int    `mapstructure:"requests_per_window"`
-	Storage           string `mapstructure:"storage"`
-	WindowSize        int    `mapstructure:"window_size"`
+	Enabled           bool              `mapstructure:"enabled"`
+	Algorithm         string            `mapstructure:"algorithm"`
+	RequestsPerWindow int               `mapstructure:"requests_per_window"`
+	Burst             int               `mapstructure:"burst"`
+	Storage           string            `mapstructure:"storage"`
+	WindowSize        int               `mapstructure:"window_size"`
+	Identity          string            `mapstructure:"identity"`
+	Header            string            `mapstructure:"header"`
+	Policies          []RateLimitPolicy `mapstructure:"policies"`
+}
+
+// RateLimitPolicy — лимит для отдельных маршрутов. Незаданные поля
+// берутся из общих настроек rate_limiter.
+type RateLimitPolicy struct {
+	Name              string   `mapstructure:"name"`
+	Routes            []string `mapstructure:"routes"` // шаблоны http.ServeMux, например "POST /upload"
+	Exempt            bool     `mapstructure:"exempt"`
+	Algorithm         string   `mapstructure:"algorithm"`
+	RequestsPerWindow int      `mapstructure:"requests_per_window"`
+	Burst             int      `mapstructure:"burst"`
+	WindowSize        int      `mapstructure:"window_size"`
+	Identity          string   `mapstructure:"identity"`
+	Header            string   `mapstructure:"header"`
+}
+
+// Concurrency — ограничение одновременных загрузок и обработок на всех экземплярах
+type Concurrency struct {
+	Enabled     bool `mapstructure:"enabled"`
+	PerIdentity int  `mapstructure:"per_identity"`
+	Uploads     int  `mapstructure:"uploads"`
+	Processing  int  `mapstructure:"processing"`
+	LeaseTTL    int  `mapstructure:"lease_ttl"`   // секунд
+	Status      int  `mapstructure:"status"`      // 429 или 503
+	RetryAfter  int  `mapstructure:"retry_after"` // секунд
 }
 
 type Memcached struct {
-	Enable     bool     `mapstructure:"enable"`
-	Servers    []string `mapstructure:"servers"`
-	DefaultTTL int      `mapstructure:"default_ttl"`
-	KeyPrefix  string   `mapstructure:"key_prefix"`
+	Enable       bool           `mapstructure:"enable"`
+	Servers      []string       `mapstructure:"servers"`
+	DefaultTTL   int            `mapstructure:"default_ttl"`
+	KeyPrefix    string         `mapstructure:"key_prefix"`
+	Timeout      int            `mapstructure:"timeout"`
+	DialTimeout  int            `mapstructure:"dial_timeout"`
+	MaxIdleConns int            `mapstructure:"max_idle_conns"`
+	Retry        Retry          `mapstructure:"retry"`
+	Breaker      CircuitBreaker `mapstructure:"breaker"`
+	// Replication — число копий по семействам ключей, например operation: 2
+	Replication map[string]int `mapstructure:"replication"`
+}
+
+// Retry — повторы при сетевых ошибках
+type Retry struct {
+	Attempts  int `mapstructure:"attempts"`
+	BaseDelay int `mapstructure:"base_delay"`
+	MaxDelay  int `mapstructure:"max_delay"`
+}
+
+// CircuitBreaker — размыкание после серии сбоев подряд
+type CircuitBreaker struct {
+	Failures    int `mapstructure:"failures"`
+	OpenTimeout int `mapstructure:"open_timeout"`
+}
+
+// Codec — сжатие и шифрование значений во внешних хранилищах
+type Codec struct {
+	Compression       string     `mapstructure:"compression"`
+	CompressThreshold int        `mapstructure:"compress_threshold"`
+	ActiveKey         string     `mapstructure:"active_key"`
+	Keys              []CodecKey `mapstructure:"keys"`
+	KeyFile           string     `mapstructure:"key_file"`
+	Families          []string   `mapstructure:"families"`
+	AllowPlaintext    bool       `mapstructure:"allow_plaintext"`
+}
+
+// CodecKey — ключ AES-256 в base64 и его идентификатор в заголовке значения
+type CodecKey struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"`
+}
+
+// Namespaces — поколения семейств ключей для массовой инвалидации
+type Namespaces struct {
+	RefreshInterval int `mapstructure:"refresh_interval"`
+}
+
+// Admin — служебные эндпоинты, выключены без токена
+type Admin struct {
+	Token string `mapstructure:"token"`
+}
+
+type Memory struct {
+	MaxBytes        int64 `mapstructure:"max_bytes"`
+	Shards          int   `mapstructure:"shards"`
+	CleanupInterval int   `mapstructure:"cleanup_interval"`
+}
+
+type Redis struct {
+	Addr        string `mapstructure:"addr"`
+	Username    string `mapstructure:"username"`
+	Password    string `mapstructure:"password"`
+	DB          int    `mapstructure:"db"`
+	DialTimeout int    `mapstructure:"dial_timeout"`
+	KeyPrefix   string `mapstructure:"key_prefix"`
 }
 
 type Files struct {
@@ -45,6 +145,31 @@ type Files struct {
 	MaxFileSize        int64    `mapstructure:"max_file_size"`
 	MaxProcessingTime  int      `mapstructure:"max_processing_time"`
 	AllowedMIMETypes   []string `mapstructure:"allowed_mime_types"`
+	Workers            int      `mapstructure:"workers"`
+	QueueSize          int      `mapstructure:"queue_size"`
+	Storage            string   `mapstructure:"storage"`
+}
+
+type Comparison struct {
+	LowerIsBetter          []string `mapstructure:"lower_is_better"`
+	StableThresholdPercent float64  `mapstructure:"stable_threshold_percent"`
+}
+
+type Export struct {
+	CSVDelimiter string `mapstructure:"csv_delimiter"`
+}
+
+type ReferenceBand struct {
+	Indicator string  `mapstructure:"indicator"`
+	Label     string  `mapstructure:"label"`
+	From      float64 `mapstructure:"from"`
+	To        float64 `mapstructure:"to"`
+}
+
+type Charts struct {
+	Width          int             `mapstructure:"width"`
+	Height         int             `mapstructure:"height"`
+	ReferenceBands []ReferenceBand `mapstructure:"reference_bands"`
 }
 
 type Metrics struct {
@@ -64,9 +189,19 @@ type Jaeger struct {
 type Config struct {
 	Server      Server      `mapstructure:"server"`
 	CORS        CORS        `mapstructure:"cors"`
+	ClientIP    ClientIP    `mapstructure:"client_ip"`
 	RateLimiter RateLimiter `mapstructure:"rate_limiter"`
+	Concurrency Concurrency `mapstructure:"concurrency"`
 	Memcached   Memcached   `mapstructure:"memcached"`
+	Codec       Codec       `mapstructure:"codec"`
+	Namespaces  Namespaces  `mapstructure:"namespaces"`
+	Admin       Admin       `mapstructure:"admin"`
+	Memory      Memory      `mapstructure:"memory"`
+	Redis       Redis       `mapstructure:"redis"`
 	Files       Files       `mapstructure:"files"`
+	Comparison  Comparison  `mapstructure:"comparison"`
+	Export      Export      `mapstructure:"export"`
+	Charts      Charts      `mapstructure:"charts"`
 	Metrics     Metrics     `mapstructure:"metrics"`
 	Logging     Logging     `mapstructure:"logging"`
 	Jaeger      Jaeger      `yaml:"jaeger"`
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+)
+
+// Семейство ключей — часть ключа до двоеточия, например operation или rate_limit
+var familyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// NamespaceBumper увеличивает поколение семейства ключей во всех хранилищах
+type NamespaceBumper interface {
+	Bump(ctx context.Context, family string) (map[string]uint64, error)
+}
+
+// AdminHandler — служебные эндпоинты, доступные по токену из конфига
+type AdminHandler struct {
+	namespaces NamespaceBumper
+	token      string
+	log        *logger.Logger
+}
+
+func NewAdminHandler(namespaces NamespaceBumper, log *logger.Logger, cfg config.Config) *AdminHandler {
+	return &AdminHandler{
+		namespaces: namespaces,
+		token:      cfg.Admin.Token,
+		log:        log,
+	}
+}
+
+type bumpResponse struct {
+	Family      string            `json:"family"`
+	Generations map[string]uint64 `json:"generations"`
+}
+
+// BumpNamespace — POST /admin/cache/{family}/bump, разом инвалидирует все ключи семейства
+func (h *AdminHandler) BumpNamespace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	family := r.PathValue("family")
+	if !familyPattern.MatchString(family) {
+		writeError(w, http.StatusBadRequest, "invalid key family")
+		return
+	}
+
+	generations, err := h.namespaces.Bump(ctx, family)
+	if err != nil {
+		log.Error("cannot bump key family generation", "family", family, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot bump key family generation")
+		return
+	}
+
+	log.Warn("key family invalidated", "family", family, "generations", generations)
+	writeJSON(w, http.StatusOK, bumpResponse{Family: family, Generations: generations})
+}
+
+// authorized проверяет Bearer-токен. Без токена в конфиге эндпоинты закрыты.
+func (h *AdminHandler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
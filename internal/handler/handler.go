@@ -1 +1,614 @@
 package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/chart"
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/export"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/idempotency"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/Caritas-Team/reviewer/internal/usecase/report"
+	"github.com/google/uuid"
+)
+
+// OperationKeyHeader — заголовок с ключом идемпотентности
+const OperationKeyHeader = "X-Operation-Key"
+
+// ReplayedHeader выставляется, когда ответ повторён по уже использованному ключу
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Максимальная длина ключа идемпотентности
+const maxOperationKeyLength = 128
+
+// Сколько раз пробуем сохранить ответ для ключа идемпотентности
+const completeAttempts = 3
+
+// Поле формы с файлами
+const filesFormField = "files"
+
+// Ошибки приёма файлов, о которых сообщаем клиенту
+var (
+	errInvalidForm     = errors.New("invalid multipart form")
+	errNoFiles         = errors.New("no files provided")
+	errTooManyFiles    = errors.New("too many files")
+	errUnsupportedType = errors.New("unsupported file type")
+)
+
+type FileHandler struct {
+	storage      *file.Storage
+	operations   *operation.Store
+	scheduler    *file.Scheduler
+	idempotency  *idempotency.Store
+	comparisons  *comparison.Service
+	csvExporter  *export.CSVExporter
+	charts       *report.ChartBuilder
+	log          *logger.Logger
+	maxFiles     int
+	maxFileSize  int64
+	allowedTypes []string
+}
+
+func NewFileHandler(
+	storage *file.Storage,
+	operations *operation.Store,
+	scheduler *file.Scheduler,
+	idempotencyStore *idempotency.Store,
+	comparisons *comparison.Service,
+	csvExporter *export.CSVExporter,
+	charts *report.ChartBuilder,
+	log *logger.Logger,
+	cfg config.Config,
+) *FileHandler {
+	return &FileHandler{
+		storage:      storage,
+		operations:   operations,
+		scheduler:    scheduler,
+		idempotency:  idempotencyStore,
+		comparisons:  comparisons,
+		csvExporter:  csvExporter,
+		charts:       charts,
+		log:          log,
+		maxFiles:     cfg.Files.MaxFilesPerRequest,
+		maxFileSize:  cfg.Files.MaxFileSize,
+		allowedTypes: cfg.Files.AllowedMIMETypes,
+	}
+}
+
+type uploadResponse struct {
+	IDs []string `json:"ids"`
+}
+
+type statusResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Upload — POST /upload, принимает PDF-файлы и создаёт по операции на каждый файл
+func (h *FileHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	operationKey := r.Header.Get(OperationKeyHeader)
+	if operationKey == "" {
+		writeError(w, http.StatusBadRequest, "missing "+OperationKeyHeader+" header")
+		return
+	}
+	if !validOperationKey(operationKey) {
+		writeError(w, http.StatusBadRequest, "invalid "+OperationKeyHeader+" header")
+		return
+	}
+
+	// Тело ограничено всеми файлами плюс запасом на служебные части формы.
+	// Заведомо большой запрос отклоняем до чтения тела: при Expect: 100-continue
+	// клиент так и не начнёт передавать файлы.
+	if h.maxFiles > 0 && h.maxFileSize > 0 {
+		maxBody := int64(h.maxFiles)*h.maxFileSize + 1<<20
+		if r.ContentLength > maxBody {
+			metrics.UpdateFileUploadError()
+			skipBody(w, r)
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	}
+
+	uploads, err := h.receiveFiles(r)
+	if err != nil {
+		metrics.UpdateFileUploadError()
+		h.storage.Remove(uploads)
+
+		status, message := uploadErrorResponse(err)
+		if status == http.StatusInternalServerError {
+			log.Error("cannot save uploaded file", "err", err)
+		}
+		writeError(w, status, message)
+		return
+	}
+
+	// Ключ привязан к набору файлов, а не к адресу клиента
+	hashes := make([][]byte, 0, len(uploads))
+	for _, u := range uploads {
+		hashes = append(hashes, u.Hash)
+	}
+	fingerprint := idempotency.Fingerprint(hashes)
+
+	replayIDs, err := h.idempotency.Reserve(ctx, operationKey, fingerprint)
+	switch {
+	case errors.Is(err, idempotency.ErrKeyConflict), errors.Is(err, idempotency.ErrInProgress):
+		h.storage.Remove(uploads)
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.storage.Remove(uploads)
+		log.Error("cannot reserve operation key", "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot reserve operation key")
+		return
+	case replayIDs != nil:
+		h.storage.Remove(uploads)
+		log.Info("upload replayed", "ids", replayIDs)
+		w.Header().Set(ReplayedHeader, "true")
+		writeJSON(w, http.StatusOK, uploadResponse{IDs: replayIDs})
+		return
+	}
+
+	batch := uuid.New().String()
+	ids := make([]string, 0, len(uploads))
+	for _, u := range uploads {
+		err = h.operations.Create(ctx, &operation.Operation{
+			ID:             u.ID,
+			IdempotencyKey: operationKey,
+			Owner:          clientIP(r),
+			Filename:       u.Filename,
+			Size:           u.Size,
+			SHA256:         hex.EncodeToString(u.Hash),
+			Batch:          batch,
+		})
+		if err != nil {
+			metrics.UpdateFileUploadError()
+			log.Error("cannot register uploaded file", "filename", u.Filename, "err", err)
+			h.discardUpload(r, operationKey, ids, uploads)
+			writeError(w, http.StatusInternalServerError, "cannot save uploaded file")
+			return
+		}
+
+		metrics.UpdateFileUploadSuccess()
+		ids = append(ids, u.ID)
+	}
+
+	// Без списка запроса отложенные операции не узнают соседей и завершатся
+	// каждая своим отчётом, поэтому загрузка отменяется целиком
+	if err = h.operations.SaveBatch(ctx, batch, ids); err != nil {
+		metrics.UpdateFileUploadError()
+		log.Error("cannot save batch", "batch", batch, "err", err)
+		h.discardUpload(r, operationKey, ids, uploads)
+		writeError(w, http.StatusInternalServerError, "cannot save uploaded file")
+		return
+	}
+
+	// Без сохранённого ответа ключ остался бы занятым до истечения срока и
+	// повторы клиента получали бы 409, поэтому при неудаче загрузка отменяется
+	if err = h.completeKey(ctx, operationKey, fingerprint, ids); err != nil {
+		metrics.UpdateFileUploadError()
+		log.Error("cannot save operation key response", "err", err)
+		h.discardUpload(r, operationKey, ids, uploads)
+		writeError(w, http.StatusInternalServerError, "cannot save operation key response")
+		return
+	}
+
+	// Операция, не попавшая в очередь, сразу получает ERROR — клиент увидит это в /get
+	for _, id := range ids {
+		if err := h.scheduler.Enqueue(id); err != nil {
+			log.Warn("cannot enqueue operation", "id", id, "err", err)
+			h.scheduler.Fail(ctx, id, err.Error())
+		}
+	}
+
+	log.Info("files uploaded", "count", len(ids), "ids", ids)
+	writeJSON(w, http.StatusOK, uploadResponse{IDs: ids})
+}
+
+// receiveFiles читает форму по частям и пишет файлы сразу на диск.
+// При ошибке возвращает и уже записанные файлы, чтобы вызывающий их удалил.
+func (h *FileHandler) receiveFiles(r *http.Request) ([]file.Upload, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errInvalidForm
+	}
+
+	var uploads []file.Upload
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return uploads, fmt.Errorf("%w: %w", errInvalidForm, err)
+		}
+
+		if part.FormName() != filesFormField || part.FileName() == "" {
+			// Остальные поля формы не используются
+			_, err = io.Copy(io.Discard, part)
+			_ = part.Close()
+			if err != nil {
+				return uploads, fmt.Errorf("%w: %w", errInvalidForm, err)
+			}
+			continue
+		}
+
+		if h.maxFiles > 0 && len(uploads) >= h.maxFiles {
+			_ = part.Close()
+			return uploads, errTooManyFiles
+		}
+
+		upload, err := h.receiveFile(part)
+		_ = part.Close()
+		if err != nil {
+			return uploads, err
+		}
+
+		metrics.UpdateFileSize(float64(upload.Size))
+		uploads = append(uploads, upload)
+	}
+
+	if len(uploads) == 0 {
+		return nil, errNoFiles
+	}
+	return uploads, nil
+}
+
+// receiveFile проверяет заявленный и фактический тип файла и сохраняет его
+func (h *FileHandler) receiveFile(part *multipart.Part) (file.Upload, error) {
+	filename := part.FileName()
+
+	if !h.isAllowedType(part.Header.Get("Content-Type")) {
+		return file.Upload{}, fmt.Errorf("%w: %s", errUnsupportedType, filename)
+	}
+
+	// Заявленный тип может быть любым из разрешённых, в том числе
+	// application/octet-stream, поэтому по первым байтам проверяем, что это PDF,
+	// до того как что-либо записать на диск
+	src := bufio.NewReader(part)
+	head, err := src.Peek(file.SignatureLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return file.Upload{}, fmt.Errorf("%w: %w", errInvalidForm, err)
+	}
+	if !file.IsPDF(head) {
+		return file.Upload{}, fmt.Errorf("%w: %s", errUnsupportedType, filename)
+	}
+
+	upload, err := h.storage.Write(filename, src, h.maxFileSize)
+	if err != nil {
+		return file.Upload{}, fmt.Errorf("%w: %s", err, filename)
+	}
+	return upload, nil
+}
+
+// uploadErrorResponse подбирает ответ клиенту по ошибке приёма файлов
+func uploadErrorResponse(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, "request body too large"
+	case errors.Is(err, errInvalidForm):
+		return http.StatusBadRequest, errInvalidForm.Error()
+	case errors.Is(err, errUnsupportedType), errors.Is(err, file.ErrNotPDF):
+		return http.StatusUnsupportedMediaType, err.Error()
+	case errors.Is(err, errNoFiles), errors.Is(err, errTooManyFiles), errors.Is(err, file.ErrFileTooLarge):
+		return http.StatusBadRequest, err.Error()
+	default:
+		return http.StatusInternalServerError, "cannot save uploaded file"
+	}
+}
+
+// Get — GET /get?id=..., возвращает статус операции или готовый результат (PDF или CSV)
+func (h *FileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing id parameter")
+		return
+	}
+
+	op, err := h.operations.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "operation not found")
+			return
+		}
+		log.Error("cannot get operation status", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot get operation status")
+		return
+	}
+
+	if op.Ready() {
+		switch negotiate(r.Header.Get("Accept"), "application/json", "application/pdf", "text/csv") {
+		case "application/pdf":
+			h.sendPDF(w, r, id)
+			return
+		case "text/csv":
+			h.sendCSV(w, r, id)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{
+		ID:     id,
+		Status: string(op.Status),
+		Error:  op.Error,
+	})
+}
+
+// sendPDF отдаёт сгенерированный отчёт операции и помечает её как скачанную
+func (h *FileHandler) sendPDF(w http.ResponseWriter, r *http.Request, id string) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	f, err := h.storage.OpenReport(id)
+	if err != nil {
+		log.Error("cannot open result file", "id", id, "err", err)
+		writeError(w, http.StatusNotFound, "result file not found")
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.pdf"`)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(w, f); err != nil {
+		log.Error("cannot send result file", "id", id, "err", err)
+		return
+	}
+
+	if _, err = h.operations.Transition(ctx, id, operation.StatusDownloaded, ""); err != nil {
+		log.Error("cannot mark operation as downloaded", "id", id, "err", err)
+	}
+}
+
+// releaseKey освобождает ключ идемпотентности после неудачной загрузки
+func (h *FileHandler) releaseKey(r *http.Request, operationKey string) {
+	if err := h.idempotency.Release(r.Context(), operationKey); err != nil {
+		h.log.WithContext(r.Context()).Error("cannot release operation key", "err", err)
+	}
+}
+
+// completeKey сохраняет ответ для ключа, повторяя попытку при временной ошибке
+// хранилища. Расхождение отпечатков не исправится повтором.
+func (h *FileHandler) completeKey(ctx context.Context, operationKey, fingerprint string, ids []string) error {
+	var err error
+	for attempt := 0; attempt < completeAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+			}
+		}
+
+		err = h.idempotency.Complete(ctx, operationKey, fingerprint, ids)
+		if err == nil || errors.Is(err, idempotency.ErrKeyConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// discardUpload удаляет созданные операции и файлы и освобождает ключ,
+// чтобы клиент мог повторить загрузку
+func (h *FileHandler) discardUpload(r *http.Request, operationKey string, ids []string, uploads []file.Upload) {
+	ctx := context.WithoutCancel(r.Context())
+	for _, id := range ids {
+		if err := h.operations.Delete(ctx, id); err != nil {
+			h.log.WithContext(ctx).Error("cannot delete operation", "id", id, "err", err)
+		}
+	}
+	h.storage.Remove(uploads)
+	h.releaseKey(r, operationKey)
+}
+
+// sendCSV выгружает сравнение диагностик операции в CSV
+func (h *FileHandler) sendCSV(w http.ResponseWriter, r *http.Request, id string) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	result, err := h.comparisons.ForOperation(ctx, id)
+	if err != nil {
+		log.Error("cannot compare diagnostics", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot compare diagnostics")
+		return
+	}
+
+	// Собираем файл целиком, чтобы при ошибке вернуть корректный ответ
+	var buf bytes.Buffer
+	if err = h.csvExporter.Export(&buf, result); err != nil {
+		log.Error("cannot export csv", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot export csv")
+		return
+	}
+
+	w.Header().Set("Content-Type", h.csvExporter.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = buf.WriteTo(w); err != nil {
+		log.Error("cannot send csv", "id", id, "err", err)
+	}
+}
+
+// Chart — GET /get/chart?id=...&indicator=..., график динамики показателей операции.
+// Параметр indicator можно повторять, без него на график попадают все показатели.
+// Формат выбирается по Accept: image/svg+xml (по умолчанию) или image/png.
+func (h *FileHandler) Chart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := h.log.WithContext(ctx)
+
+	query := r.URL.Query()
+	id := query.Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing id parameter")
+		return
+	}
+
+	op, err := h.operations.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "operation not found")
+			return
+		}
+		log.Error("cannot get operation status", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot get operation status")
+		return
+	}
+	if !op.Ready() {
+		writeError(w, http.StatusConflict, "operation is not completed")
+		return
+	}
+
+	result, err := h.comparisons.ForOperation(ctx, id)
+	if err != nil {
+		log.Error("cannot compare diagnostics", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot compare diagnostics")
+		return
+	}
+
+	c, err := h.charts.Build(result, query["indicator"]...)
+	if err != nil {
+		if errors.Is(err, report.ErrIndicatorNotFound) {
+			writeError(w, http.StatusNotFound, "indicator not found")
+			return
+		}
+		log.Error("cannot build chart", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot build chart")
+		return
+	}
+
+	contentType := negotiate(r.Header.Get("Accept"), "image/svg+xml", "image/png")
+
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = chart.RenderPNG(&buf, c)
+	} else {
+		err = chart.RenderSVG(&buf, c)
+	}
+	if err != nil {
+		log.Error("cannot render chart", "id", id, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot render chart")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = buf.WriteTo(w); err != nil {
+		log.Error("cannot send chart", "id", id, "err", err)
+	}
+}
+
+func (h *FileHandler) isAllowedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(h.allowedTypes, mediaType)
+}
+
+// validOperationKey допускает только печатные ASCII-символы без пробелов
+func validOperationKey(key string) bool {
+	if len(key) > maxOperationKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, errorResponse{Error: message})
+}
+
+// negotiate выбирает из offers тип с наибольшим q в заголовке Accept.
+// При пустом заголовке или отсутствии совпадений возвращается первый вариант.
+func negotiate(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestExact := offers[0], 0.0, false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		for _, offer := range offers {
+			if !matchMediaType(mediaType, offer) {
+				continue
+			}
+			// Точное совпадение важнее шаблона при равном q
+			exact := mediaType == offer
+			if q > bestQ || (q == bestQ && exact && !bestExact) {
+				best, bestQ, bestExact = offer, q, exact
+			}
+		}
+	}
+
+	if bestQ == 0 {
+		return offers[0]
+	}
+	return best
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
@@ -0,0 +1,600 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/export"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/idempotency"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/Caritas-Team/reviewer/internal/usecase/report"
+	"github.com/google/uuid"
+)
+
+// failingCache отказывает в записи ключей с префиксом prefix и запоминает
+// удалённые ключи
+type failingCache struct {
+	memcached.CacheInterface
+	prefix  string
+	deleted []string
+}
+
+func (c *failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if c.prefix != "" && strings.HasPrefix(key, c.prefix) {
+		return errors.New("storage unavailable")
+	}
+	return c.CacheInterface.Set(ctx, key, value, ttl)
+}
+
+func (c *failingCache) Delete(ctx context.Context, key string) error {
+	c.deleted = append(c.deleted, key)
+	return c.CacheInterface.Delete(ctx, key)
+}
+
+func newTestMemoryCache(t *testing.T) *memcached.MemoryCache {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return memcached.NewMemoryCache(ctx, config.Config{})
+}
+
+// newTestFileHandler собирает FileHandler поверх cache. Файлы пишутся в
+// ./files, поэтому тест переходит во временный каталог. Планировщик не
+// запущен: операции остаются в очереди со статусом NEW.
+func newTestFileHandler(t *testing.T, cache memcached.CacheInterface) *FileHandler {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+
+	cfg := config.Config{
+		Logging:   config.Logging{Level: "error", Format: "text"},
+		Memcached: config.Memcached{DefaultTTL: 60},
+		Files: config.Files{
+			MaxFilesPerRequest: 2,
+			MaxFileSize:        1024,
+			AllowedMIMETypes:   []string{"application/pdf"},
+			Workers:            1,
+			QueueSize:          10,
+		},
+		Charts: config.Charts{Width: 640, Height: 480},
+	}
+	log := logger.NewLogger(cfg)
+
+	storage := file.NewStorage()
+	operations := operation.NewStore(cache, cfg)
+	extractor := file.NewExtractor(log, storage, cache, cfg)
+	scheduler := file.NewScheduler(log, operations, extractor, nil, cfg)
+	csvExporter, err := export.NewCSVExporter(cfg)
+	if err != nil {
+		t.Fatalf("ошибка создания экспорта CSV: %v", err)
+	}
+
+	return NewFileHandler(
+		storage,
+		operations,
+		scheduler,
+		idempotency.NewStore(cache, cfg),
+		comparison.NewService(operations, extractor, comparison.NewComparator(cfg)),
+		csvExporter,
+		report.NewChartBuilder(cfg),
+		log,
+		cfg,
+	)
+}
+
+type testFile struct {
+	name        string
+	contentType string
+	content     string
+}
+
+func pdfFile(content string) testFile {
+	return testFile{name: "diagnostic.pdf", contentType: "application/pdf", content: "%PDF-1.7 " + content}
+}
+
+// uploadRequest собирает POST /upload с файлами files от клиента ip
+func uploadRequest(t *testing.T, key, ip string, files ...testFile) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+filesFormField+`"; filename="`+f.name+`"`)
+		header.Set("Content-Type", f.contentType)
+
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatalf("ошибка создания части формы: %v", err)
+		}
+		if _, err = part.Write([]byte(f.content)); err != nil {
+			t.Fatalf("ошибка записи части формы: %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("ошибка создания формы: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if key != "" {
+		r.Header.Set(OperationKeyHeader, key)
+	}
+	r.RemoteAddr = ip + ":12345"
+	return r
+}
+
+func upload(h *FileHandler, r *http.Request) (*httptest.ResponseRecorder, uploadResponse) {
+	w := httptest.NewRecorder()
+	h.Upload(w, r)
+
+	var resp uploadResponse
+	_ = json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp)
+	return w, resp
+}
+
+// assertNoFiles проверяет, что на диске не осталось загруженных файлов
+func assertNoFiles(t *testing.T) {
+	t.Helper()
+
+	entries, err := os.ReadDir("files")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ошибка чтения каталога: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ожидался пустой каталог, найдено %d файлов", len(entries))
+	}
+}
+
+func TestFileHandler_UploadRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		files   []testFile
+		size    int64
+		want    int
+		message string
+	}{
+		{"без ключа операции", "", []testFile{pdfFile("a")}, 0, http.StatusBadRequest, "missing " + OperationKeyHeader + " header"},
+		{"ключ с пробелом", "bad key", []testFile{pdfFile("a")}, 0, http.StatusBadRequest, "invalid " + OperationKeyHeader + " header"},
+		{"слишком длинный ключ", strings.Repeat("k", maxOperationKeyLength+1), []testFile{pdfFile("a")}, 0, http.StatusBadRequest, "invalid " + OperationKeyHeader + " header"},
+		{"заявленный размер больше лимита", "key-1", []testFile{pdfFile("a")}, 10 << 20, http.StatusRequestEntityTooLarge, "request body too large"},
+		{"файл больше лимита", "key-1", []testFile{pdfFile(strings.Repeat("x", 2048))}, 0, http.StatusBadRequest, ""},
+		{"тип части не PDF", "key-1", []testFile{{name: "image.png", contentType: "image/png", content: "%PDF-1.7"}}, 0, http.StatusUnsupportedMediaType, ""},
+		{"содержимое не PDF", "key-1", []testFile{{name: "image.pdf", contentType: "application/pdf", content: "\x89PNG...."}}, 0, http.StatusUnsupportedMediaType, ""},
+		{"второй файл не PDF", "key-1", []testFile{pdfFile("a"), {name: "b.pdf", contentType: "application/pdf", content: "text"}}, 0, http.StatusUnsupportedMediaType, ""},
+		{"слишком много файлов", "key-1", []testFile{pdfFile("a"), pdfFile("b"), pdfFile("c")}, 0, http.StatusBadRequest, ""},
+		{"без файлов", "key-1", nil, 0, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestFileHandler(t, newTestMemoryCache(t))
+
+			r := uploadRequest(t, tt.key, "10.0.0.1", tt.files...)
+			if tt.size > 0 {
+				r.ContentLength = tt.size
+			}
+			w, _ := upload(h, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("ожидался %d, получил %d: %s", tt.want, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("ожидался application/json, получил %q", got)
+			}
+			var body errorResponse
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error == "" {
+				t.Errorf("ожидалось тело с ошибкой, получил %+v, %v", body, err)
+			}
+			if tt.message != "" && body.Error != tt.message {
+				t.Errorf("ожидалась ошибка %q, получил %q", tt.message, body.Error)
+			}
+			assertNoFiles(t)
+
+			// Отклонённый запрос не занимает ключ
+			if tt.key != "" && validOperationKey(tt.key) {
+				if w, _ = upload(h, uploadRequest(t, tt.key, "10.0.0.1", pdfFile("ok"))); w.Code != http.StatusOK {
+					t.Errorf("после отказа ключ должен быть свободен, получил %d: %s", w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestFileHandler_Upload(t *testing.T) {
+	h := newTestFileHandler(t, newTestMemoryCache(t))
+
+	w, resp := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a"), pdfFile("b")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получил %d: %s", w.Code, w.Body)
+	}
+	if len(resp.IDs) != 2 {
+		t.Fatalf("ожидалось 2 операции, получил %v", resp.IDs)
+	}
+	if w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("у первого запроса не должно быть заголовка %s", ReplayedHeader)
+	}
+
+	for _, id := range resp.IDs {
+		op, err := h.operations.Get(context.Background(), id)
+		if err != nil || op.Status != operation.StatusNew || op.IdempotencyKey != "key-1" {
+			t.Errorf("ожидалась операция NEW с ключом запроса, получил %+v, %v", op, err)
+		}
+		if _, err = os.Stat("files/" + id + ".pdf"); err != nil {
+			t.Errorf("файл операции не сохранён: %v", err)
+		}
+	}
+
+	batch, err := h.operations.Batch(context.Background(), resp.IDs[0])
+	if err != nil || !slices.Equal(batch, resp.IDs) {
+		t.Errorf("ожидался запрос из загруженных операций %v, получил %v, %v", resp.IDs, batch, err)
+	}
+}
+
+func TestFileHandler_UploadIdempotency(t *testing.T) {
+	t.Run("повтор возвращает исходные операции", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		_, first := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a"), pdfFile("b")))
+
+		// Порядок файлов не важен
+		w, replay := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("b"), pdfFile("a")))
+		if w.Code != http.StatusOK {
+			t.Fatalf("ожидался 200, получил %d: %s", w.Code, w.Body)
+		}
+		if w.Header().Get(ReplayedHeader) != "true" {
+			t.Errorf("ожидался заголовок %s: true", ReplayedHeader)
+		}
+		if !slices.Equal(replay.IDs, first.IDs) {
+			t.Errorf("ожидались исходные операции %v, получил %v", first.IDs, replay.IDs)
+		}
+
+		// Файлы повтора не сохраняются
+		entries, err := os.ReadDir("files")
+		if err != nil || len(entries) != 2 {
+			t.Errorf("ожидалось 2 файла, получил %d, %v", len(entries), err)
+		}
+	})
+
+	t.Run("повтор с другого адреса", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		_, first := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a")))
+
+		w, replay := upload(h, uploadRequest(t, "key-1", "10.0.0.2", pdfFile("a")))
+		if w.Code != http.StatusOK || w.Header().Get(ReplayedHeader) != "true" {
+			t.Fatalf("ожидался повтор с ответом 200, получил %d: %s", w.Code, w.Body)
+		}
+		if !slices.Equal(replay.IDs, first.IDs) {
+			t.Errorf("ожидались исходные операции %v, получил %v", first.IDs, replay.IDs)
+		}
+	})
+
+	t.Run("тот же ключ с другими файлами", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		_, first := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a")))
+
+		w, _ := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("other")))
+		if w.Code != http.StatusConflict {
+			t.Fatalf("ожидался 409, получил %d: %s", w.Code, w.Body)
+		}
+		var body errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error != idempotency.ErrKeyConflict.Error() {
+			t.Errorf("ожидалась ошибка %q, получил %+v, %v", idempotency.ErrKeyConflict, body, err)
+		}
+
+		// Отклонённые файлы удалены, исходная загрузка не тронута
+		entries, err := os.ReadDir("files")
+		if err != nil || len(entries) != 1 || entries[0].Name() != first.IDs[0]+".pdf" {
+			t.Errorf("ожидался только файл исходной загрузки, получил %v, %v", entries, err)
+		}
+	})
+
+	t.Run("ответ для ключа не сохранился", func(t *testing.T) {
+		cache := &failingCache{CacheInterface: newTestMemoryCache(t), prefix: "idempotency:"}
+		h := newTestFileHandler(t, cache)
+
+		// Ключ удаётся занять, но не сохранить ответ
+		w, _ := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a"), pdfFile("b")))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("ожидался 500, получил %d: %s", w.Code, w.Body)
+		}
+		assertNoFiles(t)
+
+		var deleted []string
+		for _, key := range cache.deleted {
+			if id, ok := strings.CutPrefix(key, "operation:"); ok {
+				deleted = append(deleted, id)
+			}
+		}
+		if len(deleted) != 2 {
+			t.Fatalf("ожидалось удаление 2 операций, удалены %v", deleted)
+		}
+		for _, id := range deleted {
+			if _, err := h.operations.Get(context.Background(), id); !errors.Is(err, operation.ErrNotFound) {
+				t.Errorf("ожидалась ErrNotFound, получил %v", err)
+			}
+		}
+
+		// Ключ освобождён, повтор проходит как новый запрос
+		cache.prefix = ""
+		w, resp := upload(h, uploadRequest(t, "key-1", "10.0.0.1", pdfFile("a"), pdfFile("b")))
+		if w.Code != http.StatusOK || w.Header().Get(ReplayedHeader) != "" {
+			t.Fatalf("ожидался новый запрос с ответом 200, получил %d: %s", w.Code, w.Body)
+		}
+
+		entries, err := os.ReadDir("files")
+		if err != nil || len(entries) != len(resp.IDs) {
+			t.Errorf("ожидались только файлы повтора, получил %d, %v", len(entries), err)
+		}
+	})
+}
+
+const testReport = "%PDF-1.7 report"
+
+// createTestOperation создаёт операцию и проводит её до статуса status
+func createTestOperation(t *testing.T, h *FileHandler, status operation.Status) string {
+	t.Helper()
+	ctx := context.Background()
+
+	id := uuid.New().String()
+	if err := h.operations.Create(ctx, &operation.Operation{ID: id, Filename: "diagnostic.pdf"}); err != nil {
+		t.Fatalf("ошибка создания операции: %v", err)
+	}
+
+	var path []operation.Status
+	switch status {
+	case operation.StatusProgress:
+		path = []operation.Status{operation.StatusProgress}
+	case operation.StatusDone, operation.StatusError:
+		path = []operation.Status{operation.StatusProgress, status}
+	case operation.StatusDownloaded:
+		path = []operation.Status{operation.StatusProgress, operation.StatusDone, status}
+	}
+	for _, next := range path {
+		message := ""
+		if next == operation.StatusError {
+			message = "broken pdf"
+		}
+		if _, err := h.operations.Transition(ctx, id, next, message); err != nil {
+			t.Fatalf("ошибка перехода в %s: %v", next, err)
+		}
+	}
+	return id
+}
+
+// saveTestResult сохраняет отчёт и результат извлечения, как это делает
+// обработка операции
+func saveTestResult(t *testing.T, h *FileHandler, cache memcached.CacheInterface, id string, indicators ...file.Indicator) {
+	t.Helper()
+
+	if err := os.MkdirAll("files", 0o750); err != nil {
+		t.Fatalf("ошибка создания каталога: %v", err)
+	}
+	err := h.storage.SaveReport(id, func(w io.Writer) error {
+		_, err := io.WriteString(w, testReport)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ошибка сохранения отчёта: %v", err)
+	}
+
+	data, err := json.Marshal(file.DiagnosticResult{
+		OperationID: id,
+		ChildID:     "child-1",
+		TestDate:    time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+		Indicators:  indicators,
+	})
+	if err != nil {
+		t.Fatalf("ошибка сериализации результата: %v", err)
+	}
+	// Ключ, под которым результат хранит file.Extractor
+	if err = memcached.SetLarge(context.Background(), cache, "extraction:"+id, data, time.Minute); err != nil {
+		t.Fatalf("ошибка сохранения результата: %v", err)
+	}
+}
+
+func get(h http.HandlerFunc, target, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestFileHandler_Get(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      operation.Status
+		noResult    bool
+		accept      string
+		want        int
+		contentType string
+		// Статус операции после запроса
+		after operation.Status
+	}{
+		{"новая операция", operation.StatusNew, true, "", http.StatusOK, "application/json", operation.StatusNew},
+		{"PDF до готовности", operation.StatusProgress, true, "application/pdf", http.StatusOK, "application/json", operation.StatusProgress},
+		{"CSV до готовности", operation.StatusProgress, true, "text/csv", http.StatusOK, "application/json", operation.StatusProgress},
+		{"ошибка обработки", operation.StatusError, true, "application/pdf", http.StatusOK, "application/json", operation.StatusError},
+		{"готовая операция без Accept", operation.StatusDone, false, "", http.StatusOK, "application/json", operation.StatusDone},
+		{"готовая операция с любым типом", operation.StatusDone, false, "*/*", http.StatusOK, "application/json", operation.StatusDone},
+		{"PDF", operation.StatusDone, false, "application/pdf", http.StatusOK, "application/pdf", operation.StatusDownloaded},
+		{"PDF предпочтительнее CSV", operation.StatusDone, false, "text/csv;q=0.5, application/pdf", http.StatusOK, "application/pdf", operation.StatusDownloaded},
+		{"CSV", operation.StatusDone, false, "text/csv", http.StatusOK, "text/csv; charset=utf-8", operation.StatusDone},
+		{"повторное скачивание PDF", operation.StatusDownloaded, false, "application/pdf", http.StatusOK, "application/pdf", operation.StatusDownloaded},
+		{"неизвестный тип", operation.StatusDone, false, "image/png", http.StatusOK, "application/json", operation.StatusDone},
+		{"отчёт не найден", operation.StatusDone, true, "application/pdf", http.StatusNotFound, "application/json", operation.StatusDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestMemoryCache(t)
+			h := newTestFileHandler(t, cache)
+
+			id := createTestOperation(t, h, tt.status)
+			if !tt.noResult {
+				saveTestResult(t, h, cache, id, file.Indicator{Name: "Внимание", Value: 42})
+			}
+
+			w := get(h.Get, "/get?id="+id, tt.accept)
+			if w.Code != tt.want {
+				t.Fatalf("ожидался %d, получил %d: %s", tt.want, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("ожидался %s, получил %q", tt.contentType, got)
+			}
+
+			switch tt.contentType {
+			case "application/pdf":
+				if w.Body.String() != testReport {
+					t.Errorf("ожидался сохранённый отчёт, получил %q", w.Body)
+				}
+				if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="`+id+`.pdf"` {
+					t.Errorf("неверный Content-Disposition: %q", got)
+				}
+			case "text/csv; charset=utf-8":
+				if !strings.Contains(w.Body.String(), "Внимание") {
+					t.Errorf("ожидался показатель в выгрузке, получил %q", w.Body)
+				}
+			case "application/json":
+				if tt.want == http.StatusOK {
+					var body statusResponse
+					if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.ID != id || body.Status != string(tt.status) {
+						t.Errorf("ожидался статус %s, получил %+v, %v", tt.status, body, err)
+					}
+					if tt.status == operation.StatusError && body.Error != "broken pdf" {
+						t.Errorf("ожидалась причина ошибки, получил %q", body.Error)
+					}
+				}
+			}
+
+			op, err := h.operations.Get(context.Background(), id)
+			if err != nil || op.Status != tt.after {
+				t.Errorf("ожидался статус %s после запроса, получил %+v, %v", tt.after, op, err)
+			}
+		})
+	}
+
+	t.Run("без id", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		if w := get(h.Get, "/get", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("ожидался 400, получил %d", w.Code)
+		}
+	})
+
+	t.Run("неизвестная операция", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		for _, id := range []string{uuid.New().String(), "not-a-uuid"} {
+			w := get(h.Get, "/get?id="+id, "application/pdf")
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: ожидался 404, получил %d", id, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("ожидался application/json, получил %q", got)
+			}
+		}
+	})
+}
+
+func TestFileHandler_Chart(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      operation.Status
+		query       string
+		accept      string
+		want        int
+		contentType string
+		prefix      string
+	}{
+		{"SVG по умолчанию", operation.StatusDone, "", "", http.StatusOK, "image/svg+xml", "<svg"},
+		{"PNG", operation.StatusDone, "", "image/png", http.StatusOK, "image/png", "\x89PNG"},
+		{"SVG предпочтительнее PNG", operation.StatusDone, "", "image/png;q=0.5, image/svg+xml", http.StatusOK, "image/svg+xml", "<svg"},
+		{"скачанная операция", operation.StatusDownloaded, "", "", http.StatusOK, "image/svg+xml", "<svg"},
+		{"выбранный показатель", operation.StatusDone, "&indicator=внимание", "image/png", http.StatusOK, "image/png", "\x89PNG"},
+		{"неизвестный показатель", operation.StatusDone, "&indicator=Память&indicator=Рост", "", http.StatusNotFound, "application/json", ""},
+		{"операция в обработке", operation.StatusProgress, "", "", http.StatusConflict, "application/json", ""},
+		{"операция с ошибкой", operation.StatusError, "", "image/png", http.StatusConflict, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestMemoryCache(t)
+			h := newTestFileHandler(t, cache)
+
+			id := createTestOperation(t, h, tt.status)
+			saveTestResult(t, h, cache, id, file.Indicator{Name: "Внимание", Value: 42}, file.Indicator{Name: "Память", Value: 7})
+
+			w := get(h.Chart, "/get/chart?id="+id+tt.query, tt.accept)
+			if w.Code != tt.want {
+				t.Fatalf("ожидался %d, получил %d: %s", tt.want, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("ожидался %s, получил %q", tt.contentType, got)
+			}
+			if tt.prefix != "" && !strings.HasPrefix(strings.TrimSpace(w.Body.String()), tt.prefix) {
+				t.Errorf("ожидался %s, тело начинается с %q", tt.contentType, w.Body.String()[:min(w.Body.Len(), 16)])
+			}
+
+			// График не меняет статус операции
+			op, err := h.operations.Get(context.Background(), id)
+			if err != nil || op.Status != tt.status {
+				t.Errorf("ожидался статус %s, получил %+v, %v", tt.status, op, err)
+			}
+		})
+	}
+
+	t.Run("ошибка для неизвестного показателя", func(t *testing.T) {
+		cache := newTestMemoryCache(t)
+		h := newTestFileHandler(t, cache)
+
+		id := createTestOperation(t, h, operation.StatusDone)
+		saveTestResult(t, h, cache, id, file.Indicator{Name: "Внимание", Value: 42})
+
+		w := get(h.Chart, "/get/chart?id="+id+"&indicator=Рост", "")
+		var body errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error != report.ErrIndicatorNotFound.Error() {
+			t.Errorf("ожидалась ошибка %q, получил %+v, %v", report.ErrIndicatorNotFound, body, err)
+		}
+	})
+
+	t.Run("без id", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		if w := get(h.Chart, "/get/chart", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("ожидался 400, получил %d", w.Code)
+		}
+	})
+
+	t.Run("неизвестная операция", func(t *testing.T) {
+		h := newTestFileHandler(t, newTestMemoryCache(t))
+
+		if w := get(h.Chart, "/get/chart?id="+uuid.New().String(), ""); w.Code != http.StatusNotFound {
+			t.Errorf("ожидался 404, получил %d", w.Code)
+		}
+	})
+}
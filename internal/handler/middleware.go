@@ -2,13 +2,18 @@ package handler
 
 import (
 	"context"
-
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/Caritas-Team/reviewer/internal/clientip"
+	"github.com/Caritas-Team/reviewer/internal/config"
 	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
 	"github.com/Caritas-Team/reviewer/internal/usecase/user"
 	"github.com/google/uuid"
 	"github.com/rs/cors"
@@ -50,36 +55,220 @@ func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
 }
 
 type RateLimiterMiddleware struct {
-	limiter *user.RateLimiter
+	log      *logger.Logger
+	policies *user.Policies
+	// routes сопоставляет запрос с политикой по тем же правилам, что и основной mux
+	routes *http.ServeMux
+	rules  map[string]*user.Policy
+}
+
+func NewRateLimiterMiddleware(policies *user.Policies, log *logger.Logger) (*RateLimiterMiddleware, error) {
+	m := &RateLimiterMiddleware{
+		log:      log,
+		policies: policies,
+		routes:   http.NewServeMux(),
+		rules:    make(map[string]*user.Policy),
+	}
+
+	for i := range policies.Rules {
+		policy := &policies.Rules[i]
+		for _, route := range policy.Routes {
+			if err := m.register(route); err != nil {
+				return nil, fmt.Errorf("error in rate limit policy %s: %w", policy.Name, err)
+			}
+			m.rules[route] = policy
+		}
+	}
+
+	return m, nil
+}
+
+// register добавляет шаблон маршрута. ServeMux паникует на некорректных
+// и конфликтующих шаблонах, превращаем это в ошибку конфига.
+func (m *RateLimiterMiddleware) register(route string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid route %q: %v", route, r)
+		}
+	}()
+	m.routes.Handle(route, http.NotFoundHandler())
+	return nil
 }
 
-func NewRateLimiterMiddleware(limiter *user.RateLimiter) *RateLimiterMiddleware {
-	return &RateLimiterMiddleware{
-		limiter: limiter,
+// policy возвращает политику запроса, для маршрутов без политики — общую
+func (m *RateLimiterMiddleware) policy(r *http.Request) *user.Policy {
+	_, pattern := m.routes.Handler(r)
+	if policy, ok := m.rules[pattern]; ok {
+		return policy
 	}
+	return &m.policies.Default
 }
 
 func (m *RateLimiterMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
+		policy := m.policy(r)
+		if policy.Exempt {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decision := policy.Limiter.Allow(r.Context(), identity(r, policy))
+		setRateLimitHeaders(w, decision)
 
-		if host, _, err := net.SplitHostPort(ip); err == nil {
-			ip = host
+		if !decision.Allowed {
+			metrics.UpdateRateLimitExceeded()
+			m.log.WithContext(r.Context()).Warn("rate limit exceeded",
+				"policy", policy.Name,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"retry_after", decision.RetryAfter.String(),
+			)
+
+			skipBody(w, r)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+			writeError(w, http.StatusTooManyRequests, "too many requests")
+			return
 		}
 
-		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-			ips := strings.Split(forwarded, ",")
-			if len(ips) > 0 {
-				ip = strings.TrimSpace(ips[0])
-			}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRateLimitHeaders выставляет заголовки RateLimit-* (draft-ietf-httpapi-ratelimit-headers).
+// Если лимит не применялся, заголовков нет.
+func setRateLimitHeaders(w http.ResponseWriter, decision user.Decision) {
+	if decision.Limit == 0 {
+		return
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+}
+
+// seconds округляет вверх: клиент, повторивший запрос раньше, снова получит 429
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// identity возвращает ключ, по которому политика считает запросы. Префикс
+// не даёт значению заголовка совпасть с IP другого клиента.
+func identity(r *http.Request, policy *user.Policy) string {
+	switch policy.Identity {
+	case user.IdentityOperationKey:
+		if key := r.Header.Get(OperationKeyHeader); key != "" {
+			return "key:" + key
+		}
+	case user.IdentityUser:
+		if id, ok := user.IDFromContext(r.Context()); ok {
+			return "user:" + id
+		}
+	case user.IdentityHeader:
+		if value := r.Header.Get(policy.Header); value != "" {
+			return "header:" + value
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// Подсказка Retry-After по умолчанию при нехватке слотов
+const defaultConcurrencyRetryAfter = 5 * time.Second
+
+// ConcurrencyMiddleware ограничивает одновременные загрузки клиента и всех клиентов.
+// Слот держится до конца обработки запроса.
+type ConcurrencyMiddleware struct {
+	limiter    *user.ConcurrencyLimiter
+	log        *logger.Logger
+	status     int
+	retryAfter time.Duration
+}
+
+func NewConcurrencyMiddleware(limiter *user.ConcurrencyLimiter, log *logger.Logger, cfg config.Config) *ConcurrencyMiddleware {
+	status := cfg.Concurrency.Status
+	if status != http.StatusServiceUnavailable {
+		status = http.StatusTooManyRequests
+	}
+
+	retryAfter := time.Duration(cfg.Concurrency.RetryAfter) * time.Second
+	if retryAfter <= 0 {
+		retryAfter = defaultConcurrencyRetryAfter
+	}
+
+	return &ConcurrencyMiddleware{
+		limiter:    limiter,
+		log:        log,
+		status:     status,
+		retryAfter: retryAfter,
+	}
+}
+
+func (m *ConcurrencyMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		identity := "ip:" + clientIP(r)
+		if id, ok := user.IDFromContext(ctx); ok {
+			identity = "user:" + id
 		}
 
-		err := m.limiter.AllowRequest(r.Context(), ip)
+		slot, err := m.limiter.AcquireUpload(ctx, identity)
 		if err != nil {
-			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			// Ближайшая аренда истекает не раньше, чем освободится слот, поэтому
+			// клиенту подсказываем меньшее из двух
+			retryAfter := m.retryAfter
+			var full *memcached.SemaphoreFullError
+			if errors.As(err, &full) {
+				retryAfter = min(retryAfter, max(full.RetryAfter, time.Second))
+			}
+
+			m.log.WithContext(ctx).Warn("concurrency limit exceeded",
+				"scope", user.ScopeUpload,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"err", err,
+			)
+
+			skipBody(w, r)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			writeError(w, m.status, "too many concurrent uploads")
 			return
 		}
+		defer slot.Release(context.WithoutCancel(ctx))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// skipBody помечает, что тело отклонённого запроса читаться не будет, и соединение
+// закроется после ответа. Клиент с Expect: 100-continue не получит 100 Continue
+// и не начнёт передавать тело.
+func skipBody(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength != 0 {
+		w.Header().Set("Connection", "close")
+	}
+}
+
+// clientIP возвращает IP клиента, определённый ClientIPMiddleware.
+// Без middleware заголовкам не доверяем и берём адрес соединения.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(logger.ClientIPKey).(string); ok && ip != "" {
+		return ip
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// ClientIPMiddleware определяет IP клиента один раз и кладёт его в контекст,
+// чтобы rate limiter, логи и метрики использовали одно значение
+func ClientIPMiddleware(resolver *clientip.Resolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := resolver.Resolve(r)
+		metrics.UpdateRequestCountByIP(ip)
 
+		r = r.WithContext(logger.WithClientIP(r.Context(), ip))
 		next.ServeHTTP(w, r)
 	})
 }
@@ -165,10 +354,8 @@ func CheckCORS(config CORSConfig) error {
 	return nil
 }
 
-// Метод проверки работоспособности rate limiting
+// Метод проверки работоспособности rate limiting. Все политики хранят
+// счётчики в одном хранилище, поэтому достаточно проверить общую.
 func (m *RateLimiterMiddleware) IsOperational() error {
-	// Проверяем, может ли rate limiter разрешить хотя бы один запрос
-	ip := "test_ip"
-	err := m.limiter.AllowRequest(context.Background(), ip)
-	return err
+	return m.policies.Default.Limiter.Ping()
 }
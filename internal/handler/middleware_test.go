@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+)
+
+func newTestRateLimiterMiddleware(t *testing.T) (*RateLimiterMiddleware, *memcached.MemoryCache) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cache := memcached.NewMemoryCache(ctx, config.Config{})
+
+	cfg := config.Config{
+		Logging: config.Logging{Level: "error", Format: "text"},
+		RateLimiter: config.RateLimiter{
+			Enabled:           true,
+			Algorithm:         user.AlgorithmFixedWindow,
+			RequestsPerWindow: 2,
+			WindowSize:        60,
+			Policies: []config.RateLimitPolicy{
+				{Name: "probes", Routes: []string{"/health", "/metrics"}, Exempt: true},
+				{Name: "upload", Routes: []string{"POST /upload"}, RequestsPerWindow: 1, WindowSize: 30, Identity: user.IdentityUser},
+				{Name: "polling", Routes: []string{"GET /get", "GET /get/chart"}, RequestsPerWindow: 5},
+			},
+		},
+	}
+
+	policies, err := user.NewPolicies(cache, cfg)
+	if err != nil {
+		t.Fatalf("ошибка создания политик: %v", err)
+	}
+	m, err := NewRateLimiterMiddleware(policies, logger.NewLogger(cfg))
+	if err != nil {
+		t.Fatalf("ошибка создания middleware: %v", err)
+	}
+	return m, cache
+}
+
+// serve выполняет запрос от клиента ip через middleware
+func serve(m *RateLimiterMiddleware, method, target, ip string) *httptest.ResponseRecorder {
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimiterMiddleware_Exempt(t *testing.T) {
+	m, _ := newTestRateLimiterMiddleware(t)
+
+	for i := 0; i < 10; i++ {
+		for _, target := range []string{"/health", "/metrics"} {
+			w := serve(m, http.MethodGet, target, "10.0.0.1")
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: служебный маршрут не должен ограничиваться, получил %d", target, w.Code)
+			}
+			if w.Header().Get("RateLimit-Limit") != "" {
+				t.Errorf("%s: у служебного маршрута не должно быть заголовков RateLimit", target)
+			}
+		}
+	}
+
+	// Служебные запросы не расходуют общий лимит
+	if w := serve(m, http.MethodGet, "/other", "10.0.0.1"); w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("ожидался нетронутый общий лимит, получил RateLimit-Remaining %q", w.Header().Get("RateLimit-Remaining"))
+	}
+}
+
+func TestRateLimiterMiddleware_Headers(t *testing.T) {
+	m, _ := newTestRateLimiterMiddleware(t)
+
+	for i, remaining := range []string{"1", "0"} {
+		w := serve(m, http.MethodGet, "/other", "10.0.0.1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("запрос %d должен был пройти, получил %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("ожидался RateLimit-Limit 2, получил %q", got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("ожидался RateLimit-Remaining %s, получил %q", remaining, got)
+		}
+		if reset, err := strconv.Atoi(w.Header().Get("RateLimit-Reset")); err != nil || reset <= 0 || reset > 60 {
+			t.Errorf("ожидался RateLimit-Reset от 1 до 60 секунд, получил %q", w.Header().Get("RateLimit-Reset"))
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("у разрешённого запроса не должно быть Retry-After")
+		}
+	}
+}
+
+func TestRateLimiterMiddleware_TooManyRequests(t *testing.T) {
+	m, _ := newTestRateLimiterMiddleware(t)
+
+	for i := 0; i < 2; i++ {
+		serve(m, http.MethodGet, "/other", "10.0.0.1")
+	}
+	w := serve(m, http.MethodGet, "/other", "10.0.0.1")
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("ожидался 429, получил %d", w.Code)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter <= 0 || retryAfter > 60 {
+		t.Errorf("ожидался Retry-After от 1 до 60 секунд, получил %q", w.Header().Get("Retry-After"))
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("ожидался RateLimit-Remaining 0, получил %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("ожидался application/json, получил %q", got)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error != "too many requests" {
+		t.Errorf("ожидалось тело с ошибкой too many requests, получил %+v, %v", body, err)
+	}
+
+	// Лимит считается по клиенту
+	if w = serve(m, http.MethodGet, "/other", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("другой клиент не должен ограничиваться, получил %d", w.Code)
+	}
+}
+
+func TestRateLimiterMiddleware_Routes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		limit  string
+	}{
+		{"политика с методом", http.MethodPost, "/upload", "1"},
+		{"другой метод — общая политика", http.MethodGet, "/upload", "2"},
+		{"политика опроса", http.MethodGet, "/get?id=1", "5"},
+		{"второй маршрут политики", http.MethodGet, "/get/chart?id=1", "5"},
+		{"HEAD совпадает с GET", http.MethodHead, "/get?id=1", "5"},
+		{"вложенный путь не совпадает", http.MethodGet, "/get/other", "2"},
+		{"маршрут без политики", http.MethodGet, "/ready", "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestRateLimiterMiddleware(t)
+
+			w := serve(m, tt.method, tt.target, "10.0.0.1")
+			if got := w.Header().Get("RateLimit-Limit"); got != tt.limit {
+				t.Errorf("ожидался RateLimit-Limit %s, получил %q", tt.limit, got)
+			}
+		})
+	}
+
+	t.Run("у политик отдельные счётчики", func(t *testing.T) {
+		m, _ := newTestRateLimiterMiddleware(t)
+
+		serve(m, http.MethodPost, "/upload", "10.0.0.1")
+		if w := serve(m, http.MethodPost, "/upload", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("вторая загрузка должна быть отклонена, получил %d", w.Code)
+		}
+		if w := serve(m, http.MethodGet, "/get?id=1", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Errorf("опрос не должен зависеть от лимита загрузок, получил %d", w.Code)
+		}
+	})
+}
+
+func TestRateLimiterMiddleware_UserIdentity(t *testing.T) {
+	m, _ := newTestRateLimiterMiddleware(t)
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	upload := func(id string) int {
+		r := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		r.RemoteAddr = "10.0.0.1:12345"
+		if id != "" {
+			r = r.WithContext(user.WithID(r.Context(), id))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Пользователи за одним IP считаются отдельно
+	for _, id := range []string{"alice", "bob"} {
+		if code := upload(id); code != http.StatusOK {
+			t.Errorf("загрузка пользователя %s должна была пройти, получил %d", id, code)
+		}
+	}
+	if code := upload("alice"); code != http.StatusTooManyRequests {
+		t.Errorf("вторая загрузка пользователя должна быть отклонена, получил %d", code)
+	}
+
+	// Без пользователя лимит считается по IP
+	if code := upload(""); code != http.StatusOK {
+		t.Errorf("первая загрузка с IP должна была пройти, получил %d", code)
+	}
+	if code := upload(""); code != http.StatusTooManyRequests {
+		t.Errorf("вторая загрузка с IP должна быть отклонена, получил %d", code)
+	}
+}
+
+func TestRateLimiterMiddleware_IsOperational(t *testing.T) {
+	m, cache := newTestRateLimiterMiddleware(t)
+
+	if err := m.IsOperational(); err != nil {
+		t.Fatalf("ожидался nil, получил %v", err)
+	}
+
+	_ = cache.Close()
+	if err := m.IsOperational(); err == nil {
+		t.Errorf("при закрытом хранилище ожидалась ошибка")
+	}
+}
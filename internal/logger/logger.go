@@ -20,6 +20,7 @@ const (
 	RequestIDKey contextKey = "request_id"
 	TraceIDKey   contextKey = "trace_id"
 	SpanIDKey    contextKey = "span_id"
+	ClientIPKey  contextKey = "client_ip"
 )
 
 // Создание логгера
@@ -120,6 +121,10 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 		fields["span_id"] = spanID
 	}
 
+	if clientIP, ok := ctx.Value(ClientIPKey).(string); ok && clientIP != "" {
+		fields["client_ip"] = clientIP
+	}
+
 	return l.WithFields(fields)
 }
 
@@ -151,6 +156,10 @@ func WithSpanID(ctx context.Context, spanID string) context.Context {
 	return context.WithValue(ctx, SpanIDKey, spanID)
 }
 
+func WithClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, ClientIPKey, clientIP)
+}
+
 // Методы логирования с мьютексом
 func (l *Logger) Info(msg string, args ...any) {
 	l.mu.Lock()
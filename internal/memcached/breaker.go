@@ -0,0 +1,126 @@
+package memcached
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+)
+
+var ErrCircuitOpen = errors.New("memcached circuit breaker is open")
+
+// CircuitState — состояние предохранителя
+type CircuitState int
+
+const (
+	// CircuitClosed — запросы идут в memcached
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen — выполняется пробный запрос, остальные отклоняются
+	CircuitHalfOpen
+	// CircuitOpen — memcached недоступен, запросы сразу получают ErrCircuitOpen
+	CircuitOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitStateOf возвращает состояние предохранителя хранилища, если он есть.
+// Обёртки с методом Unwrap, например инструментированный кэш, разворачиваются.
+func CircuitStateOf(cache CacheInterface) (CircuitState, bool) {
+	for {
+		switch c := cache.(type) {
+		case interface{ CircuitState() CircuitState }:
+			return c.CircuitState(), true
+		case interface{ Unwrap() CacheInterface }:
+			cache = c.Unwrap()
+		default:
+			return CircuitClosed, false
+		}
+	}
+}
+
+// breaker размыкается после threshold сетевых сбоев подряд. Через openTimeout
+// пропускает один пробный запрос: успех замыкает цепь, сбой снова размыкает.
+type breaker struct {
+	name        string
+	mu          sync.Mutex
+	state       CircuitState
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	now         func() time.Time
+}
+
+// name — серверы memcached, за которыми следит предохранитель, метка в метриках
+func newBreaker(name string, threshold int, openTimeout time.Duration) *breaker {
+	metrics.UpdateCircuitBreakerState(name, float64(CircuitClosed))
+	return &breaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// allow разрешает запрос или возвращает ErrCircuitOpen
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		// Этот запрос станет пробным
+		b.setState(CircuitHalfOpen)
+		return nil
+	case CircuitHalfOpen:
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+// done учитывает исход разрешённого запроса; failed — сетевой сбой
+func (b *breaker) done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.setState(CircuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(CircuitOpen)
+	}
+}
+
+func (b *breaker) current() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *breaker) setState(state CircuitState) {
+	b.state = state
+	metrics.UpdateCircuitBreakerState(b.name, float64(state))
+}
@@ -0,0 +1,161 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	b := newBreaker("test", 3, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	t.Run("размыкается после серии сбоев", func(t *testing.T) {
+		for range 3 {
+			if err := b.allow(); err != nil {
+				t.Fatalf("запрос не должен отклоняться: %v", err)
+			}
+			b.done(true)
+		}
+		if b.current() != CircuitOpen {
+			t.Fatalf("ожидалось состояние open, получил %s", b.current())
+		}
+		if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("ожидалась ErrCircuitOpen, получил %v", err)
+		}
+	})
+
+	t.Run("после паузы пропускает один пробный запрос", func(t *testing.T) {
+		now = now.Add(11 * time.Second)
+		if err := b.allow(); err != nil {
+			t.Fatalf("пробный запрос не должен отклоняться: %v", err)
+		}
+		if b.current() != CircuitHalfOpen {
+			t.Errorf("ожидалось состояние half-open, получил %s", b.current())
+		}
+		if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("второй запрос во время пробы должен отклоняться, получил %v", err)
+		}
+	})
+
+	t.Run("неудачная проба снова размыкает", func(t *testing.T) {
+		b.done(true)
+		if b.current() != CircuitOpen {
+			t.Errorf("ожидалось состояние open, получил %s", b.current())
+		}
+	})
+
+	t.Run("удачная проба замыкает", func(t *testing.T) {
+		now = now.Add(11 * time.Second)
+		if err := b.allow(); err != nil {
+			t.Fatalf("пробный запрос не должен отклоняться: %v", err)
+		}
+		b.done(false)
+		if b.current() != CircuitClosed {
+			t.Errorf("ожидалось состояние closed, получил %s", b.current())
+		}
+	})
+
+	t.Run("успех сбрасывает счётчик сбоев", func(t *testing.T) {
+		b.done(true)
+		b.done(true)
+		b.done(false)
+		b.done(true)
+		if b.current() != CircuitClosed {
+			t.Errorf("сбои не подряд не должны размыкать, получил %s", b.current())
+		}
+	})
+}
+
+func newTestMemcached(attempts, failures int) *Cache {
+	return newCache(config.Config{Memcached: config.Memcached{
+		Servers: []string{"127.0.0.1:1"},
+		Retry:   config.Retry{Attempts: attempts, BaseDelay: 1, MaxDelay: 1},
+		Breaker: config.CircuitBreaker{Failures: failures, OpenTimeout: 60},
+	}})
+}
+
+func TestCache_Retry(t *testing.T) {
+	ctx := context.Background()
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+
+	t.Run("идемпотентный запрос повторяется", func(t *testing.T) {
+		c := newTestMemcached(3, 5)
+		calls := 0
+		err := c.do(ctx, true, func() error {
+			calls++
+			if calls < 3 {
+				return readErr
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("ожидалось 3 попытки без ошибки, получил %d, %v", calls, err)
+		}
+	})
+
+	t.Run("неидемпотентный запрос повторяется только при ошибке соединения", func(t *testing.T) {
+		c := newTestMemcached(3, 5)
+		calls := 0
+		_ = c.do(ctx, false, func() error {
+			calls++
+			return readErr
+		})
+		if calls != 1 {
+			t.Errorf("ожидалась одна попытка, получил %d", calls)
+		}
+
+		c = newTestMemcached(3, 5)
+		calls = 0
+		_ = c.do(ctx, false, func() error {
+			calls++
+			if calls < 2 {
+				return dialErr
+			}
+			return nil
+		})
+		if calls != 2 {
+			t.Errorf("ожидалось 2 попытки, получил %d", calls)
+		}
+	})
+
+	t.Run("промах не повторяется и не размыкает", func(t *testing.T) {
+		c := newTestMemcached(3, 5)
+		calls := 0
+		for range 5 {
+			_ = c.do(ctx, true, func() error {
+				calls++
+				return ErrCacheMiss
+			})
+		}
+		if calls != 5 || c.CircuitState() != CircuitClosed {
+			t.Errorf("ожидалось 5 вызовов и closed, получил %d и %s", calls, c.CircuitState())
+		}
+	})
+
+	t.Run("недоступный сервер размыкает предохранитель", func(t *testing.T) {
+		c := newTestMemcached(1, 2)
+		for range 2 {
+			if _, err := c.Get(ctx, "key"); err == nil {
+				t.Fatal("ожидалась ошибка соединения")
+			}
+		}
+		if c.CircuitState() != CircuitOpen {
+			t.Fatalf("ожидалось состояние open, получил %s", c.CircuitState())
+		}
+
+		state, ok := CircuitStateOf(c)
+		if !ok || state != CircuitOpen {
+			t.Errorf("CircuitStateOf вернул %s, %v", state, ok)
+		}
+		if err := c.Ping(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("ожидалась ErrCircuitOpen, получил %v", err)
+		}
+	})
+}
@@ -0,0 +1,419 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Формат закодированного значения:
+//
+//	magic (2) | версия (1) | сжатие (1) | длина ID ключа (1) | ID ключа | nonce | данные
+//
+// Без шифрования длина ID равна нулю и nonce отсутствует. Заголовок и ключ кэша
+// входят в AAD, поэтому значение нельзя подменить или перенести под другой ключ.
+// Значения без заголовка, записанные до включения кодека, читаются как есть:
+// 0xC0 не встречается в UTF-8, так что JSON с заголовком не спутать. При
+// включённом шифровании такие значения, как и значения без ID ключа,
+// отклоняются, иначе записавший в кэш напрямую обошёл бы проверку подлинности.
+// allow_plaintext разрешает их на время перехода на шифрование.
+var codecMagic = []byte{0xC0, 0xDE}
+
+const codecVersion = 1
+
+// Алгоритмы сжатия в заголовке
+const (
+	compressionNone byte = iota
+	compressionGzip
+	compressionZstd
+)
+
+// Верхняя граница распакованного значения, защита от «zip-бомб»
+const maxDecodedSize = 64 << 20
+
+var (
+	ErrUnknownCodecKey = errors.New("unknown codec key")
+	ErrCorruptValue    = errors.New("corrupt cached value")
+)
+
+// Codec сжимает и шифрует значения выбранных семейств ключей
+type Codec struct {
+	compression byte
+	threshold   int
+	active      string
+	keys        map[string]cipher.AEAD
+	families    map[string]bool
+	// Читать незашифрованные значения при включённом шифровании
+	allowPlaintext bool
+	zstdEnc        *zstd.Encoder
+	zstdDec        *zstd.Decoder
+}
+
+func NewCodec(cfg config.Codec) (*Codec, error) {
+	c := &Codec{
+		threshold:      cfg.CompressThreshold,
+		active:         cfg.ActiveKey,
+		keys:           make(map[string]cipher.AEAD),
+		families:       make(map[string]bool, len(cfg.Families)),
+		allowPlaintext: cfg.AllowPlaintext,
+	}
+
+	switch cfg.Compression {
+	case "":
+	case "gzip":
+		c.compression = compressionGzip
+	case "zstd":
+		c.compression = compressionZstd
+	default:
+		return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+	}
+
+	keys := cfg.Keys
+	if cfg.KeyFile != "" {
+		fileKeys, err := readKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	for _, k := range keys {
+		if err := c.addKey(k); err != nil {
+			return nil, err
+		}
+	}
+	if c.active != "" && c.keys[c.active] == nil {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownCodecKey, c.active)
+	}
+
+	for _, family := range cfg.Families {
+		c.families[family] = true
+	}
+
+	// Декодер нужен всегда: значения могли быть сжаты до смены настроек.
+	// Создаётся последним, чтобы при ошибке настроек не оставлять его горутины.
+	var err error
+	if c.zstdEnc, err = zstd.NewWriter(nil); err != nil {
+		return nil, fmt.Errorf("error creating zstd encoder: %w", err)
+	}
+	c.zstdDec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxDecodedSize))
+	if err != nil {
+		_ = c.zstdEnc.Close()
+		return nil, fmt.Errorf("error creating zstd decoder: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *Codec) addKey(k config.CodecKey) error {
+	if k.ID == "" || len(k.ID) > 255 {
+		return fmt.Errorf("invalid codec key id %q", k.ID)
+	}
+	if _, exists := c.keys[k.ID]; exists {
+		return fmt.Errorf("duplicate codec key id %q", k.ID)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(k.Key))
+	if err != nil {
+		return fmt.Errorf("error decoding codec key %q: %w", k.ID, err)
+	}
+	if len(raw) != 32 {
+		return fmt.Errorf("codec key %q must be 32 bytes for AES-256, got %d", k.ID, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return fmt.Errorf("error creating cipher for key %q: %w", k.ID, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("error creating gcm for key %q: %w", k.ID, err)
+	}
+
+	c.keys[k.ID] = aead
+	return nil
+}
+
+// readKeyFile читает ключи из строк вида id=ключ_в_base64. Пустые строки
+// и строки с # пропускаются.
+func readKeyFile(path string) ([]config.CodecKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening codec key file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var keys []config.CodecKey
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, key, found := strings.Cut(text, "=")
+		if !found {
+			return nil, fmt.Errorf("codec key file %s:%d: expected id=key", path, line)
+		}
+		keys = append(keys, config.CodecKey{ID: strings.TrimSpace(id), Key: key})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading codec key file: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Enabled сообщает, меняет ли кодек значения при записи
+func (c *Codec) Enabled() bool {
+	return c.compression != compressionNone || c.active != ""
+}
+
+func (c *Codec) applies(key string) bool {
+	return c.families[KeyFamily(key)]
+}
+
+// Encode кодирует значение ключа key. Ключи вне выбранных семейств не меняются.
+func (c *Codec) Encode(key string, value []byte) ([]byte, error) {
+	if !c.applies(key) || !c.Enabled() {
+		return value, nil
+	}
+
+	compression := compressionNone
+	payload := value
+	if c.compression != compressionNone && len(value) >= c.threshold {
+		compressed, err := c.compress(c.compression, value)
+		if err != nil {
+			return nil, err
+		}
+		// Несжимаемые данные храним как есть
+		if len(compressed) < len(value) {
+			compression, payload = c.compression, compressed
+		}
+	}
+
+	header := make([]byte, 0, len(codecMagic)+3+len(c.active))
+	header = append(header, codecMagic...)
+	header = append(header, codecVersion, compression, byte(len(c.active)))
+	header = append(header, c.active...)
+
+	if c.active == "" {
+		return append(header, payload...), nil
+	}
+
+	aead := c.keys[c.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, payload, additionalData(header, key)), nil
+}
+
+// Decode раскодирует значение ключа key. Значения без заголовка возвращаются
+// как есть, если шифрование выключено или разрешён переход на него.
+func (c *Codec) Decode(key string, data []byte) ([]byte, error) {
+	if !c.applies(key) {
+		return data, nil
+	}
+	if !bytes.HasPrefix(data, codecMagic) {
+		if !c.plaintextAllowed() {
+			return nil, fmt.Errorf("%w: value is not encrypted", ErrCorruptValue)
+		}
+		return data, nil
+	}
+
+	rest := data[len(codecMagic):]
+	if len(rest) < 3 {
+		return nil, ErrCorruptValue
+	}
+	version, compression, idLen := rest[0], rest[1], int(rest[2])
+	if version != codecVersion {
+		return nil, fmt.Errorf("%w: unsupported codec version %d", ErrCorruptValue, version)
+	}
+	rest = rest[3:]
+	if len(rest) < idLen {
+		return nil, ErrCorruptValue
+	}
+	keyID := string(rest[:idLen])
+	rest = rest[idLen:]
+
+	payload := rest
+	if keyID == "" && !c.plaintextAllowed() {
+		return nil, fmt.Errorf("%w: value is not encrypted", ErrCorruptValue)
+	}
+	if keyID != "" {
+		aead, ok := c.keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCodecKey, keyID)
+		}
+		if len(rest) < aead.NonceSize() {
+			return nil, ErrCorruptValue
+		}
+		header := data[:len(data)-len(rest)]
+		nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+		var err error
+		payload, err = aead.Open(nil, nonce, ciphertext, additionalData(header, key))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptValue, err)
+		}
+	}
+
+	return c.decompress(compression, payload)
+}
+
+func (c *Codec) plaintextAllowed() bool {
+	return c.active == "" || c.allowPlaintext
+}
+
+// Close освобождает ресурсы zstd: декодер держит свои горутины
+func (c *Codec) Close() error {
+	c.zstdDec.Close()
+	return c.zstdEnc.Close()
+}
+
+func additionalData(header []byte, key string) []byte {
+	aad := make([]byte, 0, len(header)+len(key))
+	aad = append(aad, header...)
+	return append(aad, key...)
+}
+
+func (c *Codec) compress(compression byte, value []byte) ([]byte, error) {
+	switch compression {
+	case compressionZstd:
+		return c.zstdEnc.EncodeAll(value, nil), nil
+	case compressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, fmt.Errorf("error compressing value: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("error compressing value: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return value, nil
+	}
+}
+
+func (c *Codec) decompress(compression byte, payload []byte) ([]byte, error) {
+	switch compression {
+	case compressionNone:
+		return payload, nil
+	case compressionZstd:
+		value, err := c.zstdDec.DecodeAll(payload, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptValue, err)
+		}
+		return value, nil
+	case compressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptValue, err)
+		}
+		value, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptValue, err)
+		}
+		if len(value) > maxDecodedSize {
+			return nil, fmt.Errorf("%w: decoded value too large", ErrCorruptValue)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown compression %d", ErrCorruptValue, compression)
+	}
+}
+
+// CodecCache кодирует значения при записи и раскодирует при чтении.
+// Increment и Decrement работают с числами на сервере и передаются как есть.
+type CodecCache struct {
+	cache CacheInterface
+	codec *Codec
+}
+
+func NewCodecCache(cache CacheInterface, codec *Codec) *CodecCache {
+	return &CodecCache{cache: cache, codec: codec}
+}
+
+func (c *CodecCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Decode(key, data)
+}
+
+func (c *CodecCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	data, version, err := c.cache.GetWithVersion(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := c.codec.Decode(key, data)
+	return value, version, err
+}
+
+func (c *CodecCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := c.codec.Encode(key, value)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, key, data, ttl)
+}
+
+func (c *CodecCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := c.codec.Encode(key, value)
+	if err != nil {
+		return err
+	}
+	return c.cache.Add(ctx, key, data, ttl)
+}
+
+func (c *CodecCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	data, err := c.codec.Encode(key, value)
+	if err != nil {
+		return err
+	}
+	return c.cache.CompareAndSwap(ctx, key, data, version, ttl)
+}
+
+func (c *CodecCache) Delete(ctx context.Context, key string) error {
+	return c.cache.Delete(ctx, key)
+}
+
+func (c *CodecCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	return c.cache.Increment(ctx, key, value)
+}
+
+func (c *CodecCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	return c.cache.Decrement(ctx, key, value)
+}
+
+func (c *CodecCache) Ping() error {
+	return c.cache.Ping()
+}
+
+func (c *CodecCache) Close() error {
+	return errors.Join(c.cache.Close(), c.codec.Close())
+}
+
+// Unwrap возвращает обёрнутое хранилище
+func (c *CodecCache) Unwrap() CacheInterface {
+	return c.cache
+}
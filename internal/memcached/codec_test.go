@@ -0,0 +1,243 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func newTestCodec(t *testing.T, cfg config.Codec) *Codec {
+	t.Helper()
+
+	if cfg.Families == nil {
+		cfg.Families = []string{"operation"}
+	}
+	codec, err := NewCodec(cfg)
+	if err != nil {
+		t.Fatalf("ошибка создания кодека: %v", err)
+	}
+	t.Cleanup(func() { _ = codec.Close() })
+	return codec
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	value := bytes.Repeat([]byte(`{"status":"DONE","child":"A-17"}`), 100)
+
+	tests := []struct {
+		name string
+		cfg  config.Codec
+	}{
+		{"zstd", config.Codec{Compression: "zstd"}},
+		{"gzip", config.Codec{Compression: "gzip"}},
+		{"шифрование", config.Codec{ActiveKey: "k1", Keys: []config.CodecKey{{ID: "k1", Key: testKey(1)}}}},
+		{"сжатие и шифрование", config.Codec{
+			Compression: "zstd",
+			ActiveKey:   "k1",
+			Keys:        []config.CodecKey{{ID: "k1", Key: testKey(1)}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := newTestCodec(t, tt.cfg)
+
+			data, err := codec.Encode("operation:1", value)
+			if err != nil {
+				t.Fatalf("ошибка кодирования: %v", err)
+			}
+			if bytes.Contains(data, []byte("A-17")) && tt.cfg.ActiveKey != "" {
+				t.Errorf("зашифрованное значение содержит открытый текст")
+			}
+
+			decoded, err := codec.Decode("operation:1", data)
+			if err != nil {
+				t.Fatalf("ошибка декодирования: %v", err)
+			}
+			if !bytes.Equal(decoded, value) {
+				t.Errorf("значение изменилось после кодирования")
+			}
+		})
+	}
+}
+
+func TestCodec_Compression(t *testing.T) {
+	codec := newTestCodec(t, config.Codec{Compression: "zstd", CompressThreshold: 100})
+	value := bytes.Repeat([]byte("a"), 1000)
+
+	data, err := codec.Encode("operation:1", value)
+	if err != nil {
+		t.Fatalf("ошибка кодирования: %v", err)
+	}
+	if len(data) >= len(value) {
+		t.Errorf("значение выше порога должно сжиматься: %d байт", len(data))
+	}
+
+	small, err := codec.Encode("operation:1", []byte("short"))
+	if err != nil {
+		t.Fatalf("ошибка кодирования: %v", err)
+	}
+	if small[3] != compressionNone {
+		t.Errorf("значение ниже порога не должно сжиматься")
+	}
+}
+
+func TestCodec_PassThrough(t *testing.T) {
+	codec := newTestCodec(t, config.Codec{ActiveKey: "k1", Keys: []config.CodecKey{{ID: "k1", Key: testKey(1)}}})
+
+	t.Run("семейство без кодека", func(t *testing.T) {
+		data, err := codec.Encode("rate_limit:10.0.0.1", []byte("1"))
+		if err != nil || string(data) != "1" {
+			t.Errorf("счётчик должен записываться как есть, получил %q, %v", data, err)
+		}
+	})
+
+	t.Run("значение без заголовка", func(t *testing.T) {
+		legacy := []byte(`{"status":"NEW"}`)
+		compressed := newTestCodec(t, config.Codec{Compression: "zstd"})
+		value, err := compressed.Decode("operation:1", legacy)
+		if err != nil || !bytes.Equal(value, legacy) {
+			t.Errorf("старое значение должно читаться как есть, получил %q, %v", value, err)
+		}
+	})
+}
+
+func TestCodec_Plaintext(t *testing.T) {
+	keys := []config.CodecKey{{ID: "k1", Key: testKey(1)}}
+	legacy := []byte(`{"status":"DONE"}`)
+
+	// Значение с заголовком, но без шифрования
+	unencrypted, err := newTestCodec(t, config.Codec{Compression: "gzip"}).Encode("operation:1", legacy)
+	if err != nil {
+		t.Fatalf("ошибка кодирования: %v", err)
+	}
+
+	t.Run("отклоняется при шифровании", func(t *testing.T) {
+		codec := newTestCodec(t, config.Codec{ActiveKey: "k1", Keys: keys})
+		for _, data := range [][]byte{legacy, unencrypted} {
+			if _, err := codec.Decode("operation:1", data); !errors.Is(err, ErrCorruptValue) {
+				t.Errorf("ожидалась ErrCorruptValue, получил %v", err)
+			}
+		}
+	})
+
+	t.Run("читается на время перехода", func(t *testing.T) {
+		codec := newTestCodec(t, config.Codec{ActiveKey: "k1", Keys: keys, AllowPlaintext: true})
+		for _, data := range [][]byte{legacy, unencrypted} {
+			value, err := codec.Decode("operation:1", data)
+			if err != nil || !bytes.Equal(value, legacy) {
+				t.Errorf("ожидалось %q, получил %q, %v", legacy, value, err)
+			}
+		}
+	})
+}
+
+func TestCodec_KeyRotation(t *testing.T) {
+	old := newTestCodec(t, config.Codec{ActiveKey: "k1", Keys: []config.CodecKey{{ID: "k1", Key: testKey(1)}}})
+	data, err := old.Encode("operation:1", []byte("secret"))
+	if err != nil {
+		t.Fatalf("ошибка кодирования: %v", err)
+	}
+
+	rotated := newTestCodec(t, config.Codec{ActiveKey: "k2", Keys: []config.CodecKey{
+		{ID: "k1", Key: testKey(1)},
+		{ID: "k2", Key: testKey(2)},
+	}})
+	value, err := rotated.Decode("operation:1", data)
+	if err != nil || string(value) != "secret" {
+		t.Errorf("значение под старым ключом должно читаться, получил %q, %v", value, err)
+	}
+
+	withoutOld := newTestCodec(t, config.Codec{ActiveKey: "k2", Keys: []config.CodecKey{{ID: "k2", Key: testKey(2)}}})
+	if _, err = withoutOld.Decode("operation:1", data); !errors.Is(err, ErrUnknownCodecKey) {
+		t.Errorf("ожидалась ErrUnknownCodecKey, получил %v", err)
+	}
+}
+
+func TestCodec_Tampering(t *testing.T) {
+	codec := newTestCodec(t, config.Codec{ActiveKey: "k1", Keys: []config.CodecKey{{ID: "k1", Key: testKey(1)}}})
+	data, err := codec.Encode("operation:1", []byte("secret"))
+	if err != nil {
+		t.Fatalf("ошибка кодирования: %v", err)
+	}
+
+	t.Run("изменённые данные", func(t *testing.T) {
+		broken := bytes.Clone(data)
+		broken[len(broken)-1] ^= 1
+		if _, err := codec.Decode("operation:1", broken); !errors.Is(err, ErrCorruptValue) {
+			t.Errorf("ожидалась ErrCorruptValue, получил %v", err)
+		}
+	})
+
+	t.Run("значение под чужим ключом", func(t *testing.T) {
+		if _, err := codec.Decode("operation:2", data); !errors.Is(err, ErrCorruptValue) {
+			t.Errorf("ожидалась ErrCorruptValue, получил %v", err)
+		}
+	})
+}
+
+func TestNewCodec_KeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# ключи кэша\n\nk1=" + testKey(1) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("ошибка записи файла: %v", err)
+	}
+
+	if _, err := NewCodec(config.Codec{ActiveKey: "k1", KeyFile: path}); err != nil {
+		t.Errorf("ключ из файла должен загружаться: %v", err)
+	}
+
+	if _, err := NewCodec(config.Codec{ActiveKey: "missing", KeyFile: path}); !errors.Is(err, ErrUnknownCodecKey) {
+		t.Errorf("ожидалась ErrUnknownCodecKey, получил %v", err)
+	}
+
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	if _, err := NewCodec(config.Codec{Keys: []config.CodecKey{{ID: "k1", Key: short}}}); err == nil {
+		t.Errorf("короткий ключ должен отклоняться")
+	}
+}
+
+func TestCodecCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := NewMemoryCache(ctx, config.Config{})
+	codec := newTestCodec(t, config.Codec{Compression: "gzip", ActiveKey: "k1", Keys: []config.CodecKey{{ID: "k1", Key: testKey(1)}}})
+	cache := NewCodecCache(inner, codec)
+
+	if err := cache.Set(ctx, "operation:1", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+
+	raw, err := inner.Get(ctx, "operation:1")
+	if err != nil || bytes.Contains(raw, []byte("value")) {
+		t.Errorf("в хранилище должно лежать зашифрованное значение, получил %q, %v", raw, err)
+	}
+
+	err = Update(ctx, cache, "operation:1", time.Minute, func(data []byte) ([]byte, error) {
+		return append(data, '!'), nil
+	})
+	if err != nil {
+		t.Fatalf("ошибка обновления: %v", err)
+	}
+
+	value, err := cache.Get(ctx, "operation:1")
+	if err != nil || string(value) != "value!" {
+		t.Errorf("ожидалось value!, получил %q, %v", value, err)
+	}
+
+	n, err := cache.Increment(ctx, "rate_limit:ip", 1)
+	if err != nil || n != 1 {
+		t.Errorf("счётчик должен работать без кодека, получил %d, %v", n, err)
+	}
+}
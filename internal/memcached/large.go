@@ -0,0 +1,153 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Размер части: с запасом меньше лимита memcached в 1 МБ на ключ,
+// заголовки кодека и служебные данные сервера. Бюджет MemoryCache общий для
+// всех шардов, поэтому часть с манифестом помещается в него целиком.
+const largeChunkSize = 512 << 10
+
+// Больше частей не пишем: 128 МБ в кэше — явная ошибка вызывающего
+const maxLargeChunks = 256
+
+// Манифест отличается от обычных значений первым нулевым байтом,
+// поэтому GetLarge читает и значения, записанные через Set
+var largeMagic = []byte("\x00large1")
+
+// largeManifest описывает значение, разбитое на части. Части каждой записи
+// получают новое поколение в ключе, поэтому части разных записей не смешиваются.
+type largeManifest struct {
+	Generation string    `json:"generation"`
+	Size       int       `json:"size"`
+	Chunks     int       `json:"chunks"`
+	SHA256     string    `json:"sha256"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+}
+
+// SetLarge сохраняет значение любого размера. Значения до largeChunkSize
+// пишутся как есть, большие — частями и манифестом под ключом key.
+// Части пишутся до манифеста с тем же TTL и истекают раньше него, поэтому
+// GetLarge сверяет время с ExpiresAt, а не полагается на наличие манифеста.
+//
+// SetLarge, GetLarge и DeleteLarge — функции, а не методы, как и Update: они
+// работают поверх любого CacheInterface, и каждая часть проходит через все
+// обёртки (поколения, кодек, метрики) как обычное значение. Методы пришлось бы
+// повторять в каждой обёртке и в каждом хранилище.
+func SetLarge(ctx context.Context, cache CacheInterface, key string, value []byte, ttl time.Duration) error {
+	if len(value) <= largeChunkSize && !bytes.HasPrefix(value, largeMagic) {
+		return cache.Set(ctx, key, value, ttl)
+	}
+
+	chunks := (len(value) + largeChunkSize - 1) / largeChunkSize
+	if chunks > maxLargeChunks {
+		return fmt.Errorf("%w: %d bytes", ErrTooLarge, len(value))
+	}
+
+	generation := make([]byte, 8)
+	if _, err := rand.Read(generation); err != nil {
+		return fmt.Errorf("error generating chunk generation: %w", err)
+	}
+
+	sum := sha256.Sum256(value)
+	manifest := largeManifest{
+		Generation: hex.EncodeToString(generation),
+		Size:       len(value),
+		Chunks:     chunks,
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+	// Части пишутся позже этого момента и истекают не раньше манифеста
+	if ttl > 0 {
+		manifest.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+
+	for i := range chunks {
+		chunk := value[i*largeChunkSize : min((i+1)*largeChunkSize, len(value))]
+		if err := cache.Set(ctx, chunkKey(key, manifest.Generation, i), chunk, ttl); err != nil {
+			return fmt.Errorf("error saving chunk %d: %w", i, err)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("error marshalling chunk manifest: %w", err)
+	}
+	return cache.Set(ctx, key, append(bytes.Clone(largeMagic), data...), ttl)
+}
+
+// GetLarge читает значение, записанное SetLarge или Set. Отсутствующая,
+// устаревшая или не совпавшая по контрольной сумме часть читается как
+// ErrCacheMiss, а не как повреждённые данные.
+func GetLarge(ctx context.Context, cache CacheInterface, key string) ([]byte, error) {
+	data, err := cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, largeMagic) {
+		return data, nil
+	}
+
+	var manifest largeManifest
+	if err = json.Unmarshal(data[len(largeMagic):], &manifest); err != nil {
+		return nil, ErrCacheMiss
+	}
+	if manifest.Chunks <= 0 || manifest.Chunks > maxLargeChunks {
+		return nil, ErrCacheMiss
+	}
+	if manifest.Size <= 0 || manifest.Size > manifest.Chunks*largeChunkSize {
+		return nil, ErrCacheMiss
+	}
+	if !manifest.ExpiresAt.IsZero() && time.Now().After(manifest.ExpiresAt) {
+		return nil, ErrCacheMiss
+	}
+
+	value := make([]byte, 0, manifest.Size)
+	for i := range manifest.Chunks {
+		chunk, err := cache.Get(ctx, chunkKey(key, manifest.Generation, i))
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, chunk...)
+	}
+
+	sum := sha256.Sum256(value)
+	if len(value) != manifest.Size || hex.EncodeToString(sum[:]) != manifest.SHA256 {
+		return nil, ErrCacheMiss
+	}
+	return value, nil
+}
+
+// DeleteLarge удаляет значение вместе с частями
+func DeleteLarge(ctx context.Context, cache CacheInterface, key string) error {
+	data, err := cache.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var manifest largeManifest
+	if bytes.HasPrefix(data, largeMagic) && json.Unmarshal(data[len(largeMagic):], &manifest) == nil {
+		for i := range min(manifest.Chunks, maxLargeChunks) {
+			err = cache.Delete(ctx, chunkKey(key, manifest.Generation, i))
+			if err != nil && !errors.Is(err, ErrCacheMiss) {
+				return err
+			}
+		}
+	}
+
+	return cache.Delete(ctx, key)
+}
+
+// Ключ части начинается с ключа значения, поэтому попадает в то же семейство
+func chunkKey(key, generation string, i int) string {
+	return key + ":chunk:" + generation + ":" + strconv.Itoa(i)
+}
@@ -0,0 +1,198 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func newLargeTestCache(t *testing.T) *MemoryCache {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewMemoryCache(ctx, config.Config{})
+}
+
+func largeValue(t *testing.T, size int) []byte {
+	t.Helper()
+
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		t.Fatalf("ошибка генерации значения: %v", err)
+	}
+	return value
+}
+
+func readManifest(t *testing.T, cache CacheInterface, key string) largeManifest {
+	t.Helper()
+
+	data, err := cache.Get(context.Background(), key)
+	if err != nil || !bytes.HasPrefix(data, largeMagic) {
+		t.Fatalf("ожидался манифест, получил %v", err)
+	}
+	var manifest largeManifest
+	if err = json.Unmarshal(data[len(largeMagic):], &manifest); err != nil {
+		t.Fatalf("ошибка чтения манифеста: %v", err)
+	}
+	return manifest
+}
+
+func TestLarge_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cache := newLargeTestCache(t)
+
+	t.Run("малое значение пишется целиком", func(t *testing.T) {
+		if err := SetLarge(ctx, cache, "extraction:small", []byte("value"), time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+		raw, err := cache.Get(ctx, "extraction:small")
+		if err != nil || string(raw) != "value" {
+			t.Errorf("ожидалось значение без манифеста, получил %q, %v", raw, err)
+		}
+	})
+
+	t.Run("большое значение пишется частями", func(t *testing.T) {
+		value := largeValue(t, 2*largeChunkSize+100)
+		if err := SetLarge(ctx, cache, "extraction:big", value, time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+
+		if manifest := readManifest(t, cache, "extraction:big"); manifest.Chunks != 3 {
+			t.Errorf("ожидалось 3 части, получил %d", manifest.Chunks)
+		}
+
+		got, err := GetLarge(ctx, cache, "extraction:big")
+		if err != nil || !bytes.Equal(got, value) {
+			t.Errorf("значение изменилось после чтения: %v", err)
+		}
+	})
+
+	t.Run("значение, записанное через Set, читается", func(t *testing.T) {
+		if err := cache.Set(ctx, "extraction:legacy", []byte(`{"id":"1"}`), time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+		got, err := GetLarge(ctx, cache, "extraction:legacy")
+		if err != nil || string(got) != `{"id":"1"}` {
+			t.Errorf("ожидалось исходное значение, получил %q, %v", got, err)
+		}
+	})
+}
+
+func TestLarge_MemoryBudget(t *testing.T) {
+	ctx := context.Background()
+
+	// На 16 шардов приходится меньше части на шард, но общий бюджет вмещает значение
+	cache := newTestMemoryCache(t, config.Memory{Shards: 16, MaxBytes: 4 * largeChunkSize})
+	value := largeValue(t, 2*largeChunkSize+100)
+
+	if err := SetLarge(ctx, cache, "extraction:big", value, time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	got, err := GetLarge(ctx, cache, "extraction:big")
+	if err != nil || !bytes.Equal(got, value) {
+		t.Errorf("значение изменилось после чтения: %v", err)
+	}
+}
+
+func TestLarge_Miss(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("потерянная часть", func(t *testing.T) {
+		cache := newLargeTestCache(t)
+		if err := SetLarge(ctx, cache, "extraction:1", largeValue(t, 2*largeChunkSize), time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+
+		manifest := readManifest(t, cache, "extraction:1")
+		if err := cache.Delete(ctx, chunkKey("extraction:1", manifest.Generation, 1)); err != nil {
+			t.Fatalf("ошибка удаления части: %v", err)
+		}
+
+		if _, err := GetLarge(ctx, cache, "extraction:1"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+
+	t.Run("часть другой записи", func(t *testing.T) {
+		cache := newLargeTestCache(t)
+		if err := SetLarge(ctx, cache, "extraction:1", largeValue(t, 2*largeChunkSize), time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+
+		manifest := readManifest(t, cache, "extraction:1")
+		stale := largeValue(t, largeChunkSize)
+		if err := cache.Set(ctx, chunkKey("extraction:1", manifest.Generation, 0), stale, time.Minute); err != nil {
+			t.Fatalf("ошибка записи части: %v", err)
+		}
+
+		if _, err := GetLarge(ctx, cache, "extraction:1"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+
+	t.Run("манифест с неверным размером", func(t *testing.T) {
+		for _, size := range []int{-1, 0, 2*largeChunkSize + 1} {
+			cache := newLargeTestCache(t)
+			if err := SetLarge(ctx, cache, "extraction:1", largeValue(t, 2*largeChunkSize), time.Minute); err != nil {
+				t.Fatalf("ошибка записи: %v", err)
+			}
+
+			manifest := readManifest(t, cache, "extraction:1")
+			manifest.Size = size
+			data, err := json.Marshal(manifest)
+			if err != nil {
+				t.Fatalf("ошибка сериализации манифеста: %v", err)
+			}
+			if err = cache.Set(ctx, "extraction:1", append(bytes.Clone(largeMagic), data...), time.Minute); err != nil {
+				t.Fatalf("ошибка записи манифеста: %v", err)
+			}
+
+			if _, err = GetLarge(ctx, cache, "extraction:1"); !errors.Is(err, ErrCacheMiss) {
+				t.Errorf("размер %d: ожидалась ErrCacheMiss, получил %v", size, err)
+			}
+		}
+	})
+
+	t.Run("перезапись не смешивает части", func(t *testing.T) {
+		cache := newLargeTestCache(t)
+		first := largeValue(t, 2*largeChunkSize)
+		second := largeValue(t, 2*largeChunkSize)
+
+		for _, value := range [][]byte{first, second} {
+			if err := SetLarge(ctx, cache, "extraction:1", value, time.Minute); err != nil {
+				t.Fatalf("ошибка записи: %v", err)
+			}
+		}
+
+		got, err := GetLarge(ctx, cache, "extraction:1")
+		if err != nil || !bytes.Equal(got, second) {
+			t.Errorf("ожидалось последнее значение: %v", err)
+		}
+	})
+}
+
+func TestDeleteLarge(t *testing.T) {
+	ctx := context.Background()
+	cache := newLargeTestCache(t)
+
+	if err := SetLarge(ctx, cache, "extraction:1", largeValue(t, 2*largeChunkSize), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	manifest := readManifest(t, cache, "extraction:1")
+
+	if err := DeleteLarge(ctx, cache, "extraction:1"); err != nil {
+		t.Fatalf("ошибка удаления: %v", err)
+	}
+	for i := range manifest.Chunks {
+		if _, err := cache.Get(ctx, chunkKey("extraction:1", manifest.Generation, i)); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("часть %d должна быть удалена, получил %v", i, err)
+		}
+	}
+}
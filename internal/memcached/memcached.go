@@ -2,81 +2,176 @@ package memcached
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Caritas-Team/reviewer/internal/config"
 	"github.com/bradfitz/gomemcache/memcache"
 )
 
-var ErrCacheMiss = memcache.ErrCacheMiss
+var (
+	ErrCacheMiss   = memcache.ErrCacheMiss
+	ErrNotStored   = memcache.ErrNotStored
+	ErrCASConflict = memcache.ErrCASConflict
+)
 
+// Cache — кэш во внешнем memcached. Сетевые сбои повторяются с задержкой,
+// а при недоступности сервера предохранитель сразу возвращает ErrCircuitOpen.
 type Cache struct {
-	client *memcache.Client
-	ttl    time.Duration
-	prefix string
-	enable bool
+	client  *memcache.Client
+	ttl     time.Duration
+	prefix  string
+	retry   retryPolicy
+	breaker *breaker
 }
 
 type CacheInterface interface {
 	Get(ctx context.Context, key string) ([]byte, error)
+	// GetWithVersion возвращает значение и его версию для CompareAndSwap
+	GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error)
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add записывает значение, только если ключа ещё нет, иначе возвращает ErrNotStored
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// CompareAndSwap записывает значение, только если версия не изменилась после
+	// GetWithVersion. Иначе возвращает ErrCASConflict, а если ключ исчез — ErrCacheMiss
+	CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
 	Increment(ctx context.Context, key string, value uint64) (uint64, error)
 	Decrement(ctx context.Context, key string, value uint64) (uint64, error)
+	Ping() error
 	Close() error
 }
 
-func NewCache(ctx context.Context, cfg config.Config) (*Cache, error) {
+// NewCache выбирает реализацию по конфигу: memcached, если он включён,
+// иначе кэш в памяти процесса
+func NewCache(ctx context.Context, cfg config.Config) (CacheInterface, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 	if !cfg.Memcached.Enable {
-		return &Cache{enable: false}, nil
+		return NewMemoryCache(ctx, cfg), nil
+	}
+
+	var c CacheInterface
+	if replicated(cfg.Memcached) {
+		c = newReplicatedCache(cfg)
 	} else {
-		client := memcache.New(cfg.Memcached.Servers...)
+		c = newCache(cfg)
+	}
+
+	if err := c.Ping(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-		if err := client.Ping(); err != nil {
-			return nil, err
+// replicated — копии имеют смысл, если серверов несколько и хотя бы
+// одному семейству ключей нужно больше одной копии
+func replicated(cfg config.Memcached) bool {
+	if len(cfg.Servers) < 2 {
+		return false
+	}
+	for _, n := range cfg.Replication {
+		if n > 1 {
+			return true
 		}
+	}
+	return false
+}
 
-		return &Cache{
-			client: client,
-			ttl:    time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
-			prefix: cfg.Memcached.KeyPrefix,
-			enable: true,
-		}, nil
+func newCache(cfg config.Config) *Cache {
+	client := memcache.New(cfg.Memcached.Servers...)
+	client.Timeout = time.Duration(cfg.Memcached.Timeout) * time.Millisecond
+	client.MaxIdleConns = cfg.Memcached.MaxIdleConns
+	if cfg.Memcached.DialTimeout > 0 {
+		// Клиент ограничивает и установку соединения своим Timeout,
+		// поэтому dial_timeout больше timeout не действует
+		dialer := &net.Dialer{Timeout: time.Duration(cfg.Memcached.DialTimeout) * time.Millisecond}
+		client.DialContext = dialer.DialContext
+	}
+
+	failures := cfg.Memcached.Breaker.Failures
+	if failures <= 0 {
+		failures = defaultBreakerFailures
+	}
+	openTimeout := time.Duration(cfg.Memcached.Breaker.OpenTimeout) * time.Second
+	if openTimeout <= 0 {
+		openTimeout = defaultBreakerOpenTimeout
+	}
+
+	return &Cache{
+		client:  client,
+		ttl:     time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		prefix:  cfg.Memcached.KeyPrefix,
+		retry:   newRetryPolicy(cfg.Memcached.Retry),
+		breaker: newBreaker(strings.Join(cfg.Memcached.Servers, ","), failures, openTimeout),
 	}
 }
 
 func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, _, err := c.GetWithVersion(ctx, key)
+	return value, err
+}
+
+// GetWithVersion возвращает значение и CAS-идентификатор memcached
+func (c *Cache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	if !c.enable || c.client == nil {
-		return nil, memcache.ErrCacheMiss
-	}
-	prefix := c.prefix + ":" + key
-	item, err := c.client.Get(prefix)
+
+	var item *memcache.Item
+	err := c.do(ctx, true, func() (err error) {
+		item, err = c.client.Get(c.key(key))
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return item.Value, nil
+	return item.Value, item.CasID, nil
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if !c.enable || c.client == nil {
-		return memcache.ErrCacheMiss
+	return c.set(ctx, c.key(key), value, ttl)
+}
+
+// Add записывает значение, только если ключа ещё нет, иначе возвращает ErrNotStored
+func (c *Cache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	prefix := c.prefix + ":" + key
-	err := c.client.Set(&memcache.Item{
-		Key:        prefix,
-		Value:      value,
-		Expiration: int32(ttl.Seconds()),
+	return c.do(ctx, false, func() error {
+		return c.client.Add(&memcache.Item{
+			Key:        c.key(key),
+			Value:      value,
+			Expiration: int32(ttl.Seconds()),
+		})
 	})
+}
+
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := c.do(ctx, false, func() error {
+		return c.client.CompareAndSwap(&memcache.Item{
+			Key:        c.key(key),
+			Value:      value,
+			Expiration: int32(ttl.Seconds()),
+			CasID:      version,
+		})
+	})
+	// NOT_STORED означает, что ключ вытеснен между чтением и записью
+	if errors.Is(err, memcache.ErrNotStored) {
+		return ErrCacheMiss
+	}
 	return err
 }
 
@@ -84,22 +179,17 @@ func (c *Cache) Increment(ctx context.Context, key string, value uint64) (newVal
 	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
-	if !c.enable || c.client == nil {
-		return 0, memcache.ErrCacheMiss
-	}
-	prefix := c.prefix + ":" + key
+	prefix := c.key(key)
 
-	newValue, err = c.client.Increment(prefix, value)
+	err = c.do(ctx, false, func() (err error) {
+		newValue, err = c.client.Increment(prefix, value)
+		return err
+	})
 	if err != nil {
 		// Если ключа нет - создаем его с начальным значением
 		if errors.Is(err, memcache.ErrCacheMiss) {
 			initial := value
-			err = c.client.Set(&memcache.Item{
-				Key:        prefix,
-				Value:      []byte(strconv.FormatUint(initial, 10)),
-				Expiration: int32(c.ttl.Seconds()),
-			})
-			if err != nil {
+			if err = c.set(ctx, prefix, []byte(strconv.FormatUint(initial, 10)), c.ttl); err != nil {
 				return 0, err
 			}
 			return initial, nil
@@ -113,21 +203,16 @@ func (c *Cache) Decrement(ctx context.Context, key string, value uint64) (newVal
 	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
-	if !c.enable || c.client == nil {
-		return 0, memcache.ErrCacheMiss
-	}
-	prefix := c.prefix + ":" + key
+	prefix := c.key(key)
 
-	newValue, err = c.client.Decrement(prefix, value)
+	err = c.do(ctx, false, func() (err error) {
+		newValue, err = c.client.Decrement(prefix, value)
+		return err
+	})
 	if err != nil {
 		// Если ключа нет - создаем его с нулевым значением
 		if errors.Is(err, memcache.ErrCacheMiss) {
-			err = c.client.Set(&memcache.Item{
-				Key:        prefix,
-				Value:      []byte("0"),
-				Expiration: int32(c.ttl.Seconds()),
-			})
-			if err != nil {
+			if err = c.set(ctx, prefix, []byte("0"), c.ttl); err != nil {
 				return 0, err
 			}
 			return 0, nil
@@ -145,29 +230,61 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if !c.enable || c.client == nil {
-		return nil
-	}
-
-	prefix := c.prefix + ":" + key
-	return c.client.Delete(prefix)
+	return c.do(ctx, true, func() error {
+		return c.client.Delete(c.key(key))
+	})
 }
 
-var ErrCacheDisabled = errors.New("memcached caching is disabled")
-
 // Проверки
-// Ping проверяет доступность memcached
+// Ping проверяет доступность memcached. Пока предохранитель разомкнут,
+// сразу возвращает ErrCircuitOpen, а по истечении паузы служит пробным запросом.
 func (c *Cache) Ping() error {
-	if c.client == nil {
-		return ErrCacheDisabled
+	return c.do(context.Background(), true, c.client.Ping)
+}
+
+// CircuitState возвращает состояние предохранителя
+func (c *Cache) CircuitState() CircuitState {
+	return c.breaker.current()
+}
+
+func (c *Cache) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.do(ctx, true, func() error {
+		return c.client.Set(&memcache.Item{
+			Key:        key,
+			Value:      value,
+			Expiration: int32(ttl.Seconds()),
+		})
+	})
+}
+
+// Ограничения протокола memcached на ключ
+const maxKeyLength = 250
+
+// key добавляет префикс. Ключи длиннее лимита memcached или с пробелами
+// и управляющими символами заменяются хешем, чтобы идентификаторы от
+// клиента не ломали текстовый протокол. Семейство сохраняется для отладки.
+func (c *Cache) key(key string) string {
+	full := c.prefix + ":" + key
+	if legalKey(full) {
+		return full
 	}
-	return c.client.Ping()
+
+	sum := sha256.Sum256([]byte(key))
+	hashed := c.prefix + ":#" + hex.EncodeToString(sum[:])
+	if family := KeyFamily(key); family != key && len(family) <= 32 && legalKey(family) {
+		hashed = c.prefix + ":" + family + ":#" + hex.EncodeToString(sum[:])
+	}
+	return hashed
 }
 
-// IsEnabled проверяет, активирован ли кэш
-func (c *Cache) IsEnabled() error {
-	if !c.enable {
-		return ErrCacheDisabled
+func legalKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
 	}
-	return nil
+	return true
 }
@@ -0,0 +1,466 @@
+package memcached
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+var (
+	ErrCacheClosed = errors.New("cache is closed")
+	ErrTooLarge    = errors.New("value exceeds cache memory limit")
+	ErrNotNumeric  = errors.New("cannot increment or decrement non-numeric value")
+)
+
+// Значения по умолчанию для in-memory кэша
+const (
+	defaultMemoryShards    = 16
+	defaultMemoryMaxBytes  = 64 << 20
+	defaultCleanupInterval = time.Minute
+)
+
+// Служебные байты на запись: элемент списка, заголовок map и срок жизни.
+// Учитываются в бюджете, чтобы миллион пустых ключей не обходил лимит.
+const entryOverhead = 64
+
+// MemoryCache — кэш в памяти процесса с той же семантикой, что и memcached:
+// TTL на ключ, атомарные Increment/Decrement и вытеснение давно не читанных
+// записей (LRU) при превышении бюджета памяти.
+//
+// Бюджет общий для всех шардов: одна запись может занять его целиком, а при
+// переполнении вытесняются сначала записи своего шарда, затем остальных.
+type MemoryCache struct {
+	shards   []*memoryShard
+	ttl      time.Duration
+	maxBytes int64
+	used     atomic.Int64
+	// С какого шарда начинать вытеснение, чтобы не опустошать всегда первый
+	next   atomic.Uint32
+	stop   chan struct{}
+	once   sync.Once
+	closed sync.WaitGroup
+}
+
+type memoryShard struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // в начале — недавно использованные
+	size  int64
+	// Общие для всех шардов бюджет и занятый объём
+	maxBytes int64
+	used     *atomic.Int64
+	closed   bool
+	// Счётчик версий: каждая запись получает новое значение
+	version uint64
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // нулевое значение — без срока
+	version   uint64
+}
+
+func NewMemoryCache(ctx context.Context, cfg config.Config) *MemoryCache {
+	shards := cfg.Memory.Shards
+	if shards <= 0 {
+		shards = defaultMemoryShards
+	}
+
+	maxBytes := cfg.Memory.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMemoryMaxBytes
+	}
+
+	interval := time.Duration(cfg.Memory.CleanupInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+
+	c := &MemoryCache{
+		shards:   make([]*memoryShard, shards),
+		ttl:      time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		maxBytes: maxBytes,
+		stop:     make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i] = &memoryShard{
+			items:    make(map[string]*list.Element),
+			lru:      list.New(),
+			maxBytes: maxBytes,
+			used:     &c.used,
+		}
+	}
+
+	c.closed.Add(1)
+	go c.cleanup(ctx, interval)
+
+	return c
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrCacheClosed
+	}
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return clone(e.value), nil
+}
+
+func (c *MemoryCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, 0, ErrCacheClosed
+	}
+
+	e, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, 0, ErrCacheMiss
+	}
+	return clone(e.value), e.version, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer c.reclaim()
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+	return s.set(key, clone(value), expiresAt(ttl, time.Now()))
+}
+
+// Add записывает значение, только если ключа ещё нет, иначе возвращает ErrNotStored
+func (c *MemoryCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer c.reclaim()
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+
+	now := time.Now()
+	if _, ok := s.get(key, now); ok {
+		return ErrNotStored
+	}
+	return s.set(key, clone(value), expiresAt(ttl, now))
+}
+
+func (c *MemoryCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer c.reclaim()
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	if !ok {
+		return ErrCacheMiss
+	}
+	if e.version != version {
+		return ErrCASConflict
+	}
+	return s.set(key, clone(value), expiresAt(ttl, now))
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+
+	el, ok := s.items[key]
+	if !ok {
+		return ErrCacheMiss
+	}
+	s.remove(el)
+	return nil
+}
+
+// Increment увеличивает число под ключом. Отсутствующий ключ создаётся со значением
+// value и TTL по умолчанию, как в memcached.Cache
+func (c *MemoryCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	return c.update(ctx, key, value, func(current uint64) uint64 {
+		// Переполнение заворачивается через ноль, как в memcached
+		return current + value
+	})
+}
+
+// Decrement уменьшает число под ключом, не опускаясь ниже нуля.
+// Отсутствующий ключ создаётся со значением 0
+func (c *MemoryCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	return c.update(ctx, key, 0, func(current uint64) uint64 {
+		if value > current {
+			return 0
+		}
+		return current - value
+	})
+}
+
+func (c *MemoryCache) update(ctx context.Context, key string, initial uint64, apply func(uint64) uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer c.reclaim()
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrCacheClosed
+	}
+
+	now := time.Now()
+	e, ok := s.get(key, now)
+	if !ok {
+		err := s.set(key, []byte(strconv.FormatUint(initial, 10)), expiresAt(c.ttl, now))
+		return initial, err
+	}
+
+	current, err := strconv.ParseUint(string(e.value), 10, 64)
+	if err != nil {
+		return 0, ErrNotNumeric
+	}
+
+	// Срок жизни ключа сохраняется, как у incr/decr в memcached
+	next := apply(current)
+	return next, s.set(key, []byte(strconv.FormatUint(next, 10)), e.expiresAt)
+}
+
+// Ping для кэша в памяти проверяет только, что он не закрыт
+func (c *MemoryCache) Ping() error {
+	s := c.shards[0]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrCacheClosed
+	}
+	return nil
+}
+
+// Close останавливает фоновую очистку и освобождает память
+func (c *MemoryCache) Close() error {
+	c.once.Do(func() {
+		close(c.stop)
+		c.closed.Wait()
+
+		for _, s := range c.shards {
+			s.mu.Lock()
+			s.used.Add(-s.size)
+			s.items, s.lru, s.size, s.closed = nil, list.New(), 0, true
+			s.mu.Unlock()
+		}
+	})
+	return nil
+}
+
+// reclaim вытесняет записи других шардов, если после записи общий бюджет
+// всё ещё превышен. Вызывается без блокировок шардов.
+func (c *MemoryCache) reclaim() {
+	if c.used.Load() <= c.maxBytes {
+		return
+	}
+
+	start := int(c.next.Add(1))
+	for i := range c.shards {
+		if c.used.Load() <= c.maxBytes {
+			return
+		}
+
+		s := c.shards[(start+i)%len(c.shards)]
+		s.mu.Lock()
+		if !s.closed {
+			s.evict(nil)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (c *MemoryCache) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// cleanup периодически удаляет просроченные записи, которые никто не читает
+func (c *MemoryCache) cleanup(ctx context.Context, interval time.Duration) {
+	defer c.closed.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, s := range c.shards {
+				s.mu.Lock()
+				s.deleteExpired(time.Now())
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// get возвращает живую запись и поднимает её в начало LRU
+func (s *memoryShard) get(key string, now time.Time) (*memoryEntry, bool) {
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*memoryEntry)
+	if e.expired(now) {
+		s.remove(el)
+		return nil, false
+	}
+
+	s.lru.MoveToFront(el)
+	return e, true
+}
+
+func (s *memoryShard) set(key string, value []byte, expiresAt time.Time) error {
+	size := entrySize(key, value)
+	if size > s.maxBytes {
+		return ErrTooLarge
+	}
+
+	s.version++
+	el, ok := s.items[key]
+	if ok {
+		e := el.Value.(*memoryEntry)
+		s.grow(size - entrySize(e.key, e.value))
+		e.value, e.expiresAt, e.version = value, expiresAt, s.version
+		s.lru.MoveToFront(el)
+	} else {
+		entry := &memoryEntry{key: key, value: value, expiresAt: expiresAt, version: s.version}
+		el = s.lru.PushFront(entry)
+		s.items[key] = el
+		s.grow(size)
+	}
+
+	s.evict(el)
+	return nil
+}
+
+// evict освобождает место в шарде, пока превышен общий бюджет: сначала
+// просроченные записи, затем самые старые по LRU. Запись keep не вытесняется.
+func (s *memoryShard) evict(keep *list.Element) {
+	if s.used.Load() <= s.maxBytes {
+		return
+	}
+
+	s.deleteExpired(time.Now())
+	for s.used.Load() > s.maxBytes {
+		el := s.lru.Back()
+		if el == nil || el == keep {
+			return
+		}
+		s.remove(el)
+	}
+}
+
+// grow учитывает изменение объёма в шарде и в общем бюджете
+func (s *memoryShard) grow(delta int64) {
+	s.size += delta
+	s.used.Add(delta)
+}
+
+func (s *memoryShard) deleteExpired(now time.Time) {
+	for _, el := range s.items {
+		if el.Value.(*memoryEntry).expired(now) {
+			s.remove(el)
+		}
+	}
+}
+
+func (s *memoryShard) remove(el *list.Element) {
+	e := el.Value.(*memoryEntry)
+	s.lru.Remove(el)
+	delete(s.items, e.key)
+	s.grow(-entrySize(e.key, e.value))
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value) + entryOverhead)
+}
+
+// expiresAt переводит TTL в момент истечения; ttl <= 0 — запись без срока, как в memcached
+func expiresAt(ttl time.Duration, now time.Time) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
@@ -0,0 +1,222 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func newTestMemoryCache(t *testing.T, memory config.Memory) *MemoryCache {
+	t.Helper()
+
+	c := NewMemoryCache(context.Background(), config.Config{
+		Memcached: config.Memcached{DefaultTTL: 3600},
+		Memory:    memory,
+	})
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestMemoryCache_GetSet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestMemoryCache(t, config.Memory{})
+
+	t.Run("Отсутствующий ключ", func(t *testing.T) {
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+
+	t.Run("Значение копируется при записи и чтении", func(t *testing.T) {
+		value := []byte("value")
+		if err := c.Set(ctx, "key", value, time.Minute); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		value[0] = 'X'
+
+		got, err := c.Get(ctx, "key")
+		if err != nil || string(got) != "value" {
+			t.Fatalf("ожидалось value, получил %q, %v", got, err)
+		}
+		got[0] = 'Y'
+
+		if again, _ := c.Get(ctx, "key"); string(again) != "value" {
+			t.Errorf("значение в кэше изменилось: %q", again)
+		}
+	})
+
+	t.Run("Add не перезаписывает существующий ключ", func(t *testing.T) {
+		if err := c.Add(ctx, "add", []byte("1"), time.Minute); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if err := c.Add(ctx, "add", []byte("2"), time.Minute); !errors.Is(err, ErrNotStored) {
+			t.Errorf("ожидалась ErrNotStored, получил %v", err)
+		}
+	})
+
+	t.Run("Delete удаляет ключ", func(t *testing.T) {
+		_ = c.Set(ctx, "delete", []byte("1"), 0)
+		if err := c.Delete(ctx, "delete"); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if err := c.Delete(ctx, "delete"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestMemoryCache(t, config.Memory{})
+
+	_ = c.Set(ctx, "short", []byte("1"), 20*time.Millisecond)
+	_ = c.Set(ctx, "forever", []byte("1"), 0)
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("просроченный ключ должен исчезнуть, получил %v", err)
+	}
+	if _, err := c.Get(ctx, "forever"); err != nil {
+		t.Errorf("ключ без срока должен остаться, получил %v", err)
+	}
+	if err := c.Add(ctx, "short", []byte("2"), time.Minute); err != nil {
+		t.Errorf("Add должен записать вместо просроченного ключа, получил %v", err)
+	}
+}
+
+func TestMemoryCache_LRU(t *testing.T) {
+	ctx := context.Background()
+
+	// Один шард на три записи по 10 байт значения
+	c := newTestMemoryCache(t, config.Memory{Shards: 1, MaxBytes: 3 * (2 + 10 + entryOverhead)})
+	value := make([]byte, 10)
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		_ = c.Set(ctx, key, value, 0)
+	}
+
+	// k1 становится недавно использованным, вытеснен будет k2
+	_, _ = c.Get(ctx, "k1")
+	_ = c.Set(ctx, "k4", value, 0)
+
+	if _, err := c.Get(ctx, "k2"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("k2 должен быть вытеснен, получил %v", err)
+	}
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("%s должен остаться, получил %v", key, err)
+		}
+	}
+
+	if err := c.Set(ctx, "huge", make([]byte, 1000), 0); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("ожидалась ErrTooLarge, получил %v", err)
+	}
+}
+
+func TestMemoryCache_Budget(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("запись больше доли шарда", func(t *testing.T) {
+		// 16 шардов по умолчанию: при бюджете на шард в 4 МБ запись бы не поместилась
+		c := newTestMemoryCache(t, config.Memory{})
+		value := make([]byte, 5<<20)
+
+		if err := c.Set(ctx, "big", value, 0); err != nil {
+			t.Fatalf("значение меньше общего бюджета должно сохраниться, получил %v", err)
+		}
+		if got, err := c.Get(ctx, "big"); err != nil || len(got) != len(value) {
+			t.Errorf("ожидалось значение длиной %d, получил %d, %v", len(value), len(got), err)
+		}
+	})
+
+	t.Run("общий бюджет не превышается", func(t *testing.T) {
+		maxBytes := int64(20 * (4 + 100 + entryOverhead))
+		c := newTestMemoryCache(t, config.Memory{Shards: 4, MaxBytes: maxBytes})
+
+		for i := 0; i < 200; i++ {
+			if err := c.Set(ctx, "k"+strconv.Itoa(100+i), make([]byte, 100), 0); err != nil {
+				t.Fatalf("ошибка записи: %v", err)
+			}
+			if used := c.used.Load(); used > maxBytes {
+				t.Fatalf("занято %d байт при бюджете %d", used, maxBytes)
+			}
+		}
+
+		// Последняя запись вытесняет другие, а не себя
+		if _, err := c.Get(ctx, "k299"); err != nil {
+			t.Errorf("последняя запись должна остаться, получил %v", err)
+		}
+	})
+}
+
+func TestMemoryCache_IncrementDecrement(t *testing.T) {
+	ctx := context.Background()
+	c := newTestMemoryCache(t, config.Memory{})
+
+	t.Run("Отсутствующий ключ создаётся", func(t *testing.T) {
+		if v, err := c.Increment(ctx, "inc", 5); err != nil || v != 5 {
+			t.Errorf("ожидалось 5, получил %d, %v", v, err)
+		}
+		if v, err := c.Decrement(ctx, "dec", 5); err != nil || v != 0 {
+			t.Errorf("ожидалось 0, получил %d, %v", v, err)
+		}
+	})
+
+	t.Run("Decrement не уходит ниже нуля", func(t *testing.T) {
+		_ = c.Set(ctx, "floor", []byte("3"), 0)
+		if v, err := c.Decrement(ctx, "floor", 10); err != nil || v != 0 {
+			t.Errorf("ожидалось 0, получил %d, %v", v, err)
+		}
+	})
+
+	t.Run("Нечисловое значение", func(t *testing.T) {
+		_ = c.Set(ctx, "text", []byte("abc"), 0)
+		if _, err := c.Increment(ctx, "text", 1); !errors.Is(err, ErrNotNumeric) {
+			t.Errorf("ожидалась ErrNotNumeric, получил %v", err)
+		}
+	})
+
+	t.Run("Конкурентные инкременты не теряются", func(t *testing.T) {
+		const workers, perWorker = 8, 500
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < perWorker; j++ {
+					_, _ = c.Increment(ctx, "counter", 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, _ := c.Get(ctx, "counter")
+		if want := strconv.Itoa(workers * perWorker); string(got) != want {
+			t.Errorf("ожидалось %s, получил %s", want, got)
+		}
+	})
+}
+
+func TestMemoryCache_Close(t *testing.T) {
+	c := newTestMemoryCache(t, config.Memory{})
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("повторный Close не должен падать: %v", err)
+	}
+	if err := c.Ping(); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("ожидалась ErrCacheClosed, получил %v", err)
+	}
+	if _, err := c.Get(context.Background(), "key"); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("ожидалась ErrCacheClosed, получил %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Как долго поколение семейства берётся из памяти без обращения к кэшу
+const defaultNamespaceRefresh = 5 * time.Second
+
+// Префикс ключей со счётчиками поколений
+const namespaceFamily = "ns"
+
+// NamespacedCache добавляет в каждый ключ поколение его семейства:
+// operation:id хранится как operation:<поколение>:id. Увеличение поколения
+// через Bump разом делает недоступными все ключи семейства, а старые записи
+// истекают сами.
+//
+// Поколение кэшируется в памяти на refresh, поэтому другие экземпляры сервиса
+// видят увеличение с этой задержкой.
+type NamespacedCache struct {
+	cache   CacheInterface
+	refresh time.Duration
+	now     func() time.Time
+
+	mu          sync.Mutex
+	generations map[string]generation
+}
+
+type generation struct {
+	value     string
+	fetchedAt time.Time
+}
+
+func NewNamespacedCache(cache CacheInterface, refresh time.Duration) *NamespacedCache {
+	if refresh <= 0 {
+		refresh = defaultNamespaceRefresh
+	}
+	return &NamespacedCache{
+		cache:       cache,
+		refresh:     refresh,
+		now:         time.Now,
+		generations: make(map[string]generation),
+	}
+}
+
+// Bump увеличивает поколение семейства и возвращает новое значение.
+// Поколение меняется через GetWithVersion и CompareAndSwap, а не Increment:
+// при репликации семейства ns копии получают одно и то же значение, а не
+// увеличиваются каждая сама по себе.
+func (c *NamespacedCache) Bump(ctx context.Context, family string) (uint64, error) {
+	var n uint64
+	change := func(data []byte) ([]byte, error) {
+		current, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s generation: %w", family, err)
+		}
+		n = current + 1
+		return []byte(strconv.FormatUint(n, 10)), nil
+	}
+
+	var err error
+	for range 2 {
+		// Счётчик создаётся через Add, как при первом чтении поколения
+		if _, err = c.load(ctx, family); err != nil {
+			return 0, err
+		}
+
+		err = Update(ctx, c.cache, generationKey(family), 0, change)
+		// Счётчик могли вытеснить между созданием и записью — создаём заново
+		if !errors.Is(err, ErrCacheMiss) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error bumping %s generation: %w", family, err)
+	}
+
+	c.mu.Lock()
+	c.generations[family] = generation{value: strconv.FormatUint(n, 10), fetchedAt: c.now()}
+	c.mu.Unlock()
+
+	return n, nil
+}
+
+// key возвращает ключ с поколением семейства
+func (c *NamespacedCache) key(ctx context.Context, key string) (string, error) {
+	family, rest, found := strings.Cut(key, ":")
+
+	c.mu.Lock()
+	gen, ok := c.generations[family]
+	c.mu.Unlock()
+
+	if !ok || c.now().Sub(gen.fetchedAt) >= c.refresh {
+		value, err := c.load(ctx, family)
+		if err != nil {
+			return "", err
+		}
+		gen = generation{value: value, fetchedAt: c.now()}
+
+		c.mu.Lock()
+		c.generations[family] = gen
+		c.mu.Unlock()
+	}
+
+	if !found {
+		return key + ":" + gen.value, nil
+	}
+	return family + ":" + gen.value + ":" + rest, nil
+}
+
+// load читает поколение из кэша. Если счётчик вытеснен, начинаем с текущего
+// времени в наносекундах: новое поколение не совпадёт ни с одним из прежних,
+// и старые записи не воскреснут. Счётчик создаётся через Add: при репликации
+// его решает одна копия, а остальные получают то же значение.
+func (c *NamespacedCache) load(ctx context.Context, family string) (string, error) {
+	key := generationKey(family)
+	for range 2 {
+		data, err := c.cache.Get(ctx, key)
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return "", fmt.Errorf("error getting %s generation: %w", family, err)
+		}
+
+		initial := strconv.FormatInt(c.now().UnixNano(), 10)
+		err = c.cache.Add(ctx, key, []byte(initial), 0)
+		if err == nil {
+			return initial, nil
+		}
+		// Параллельно счётчик создал другой экземпляр — перечитываем
+		if !errors.Is(err, ErrNotStored) {
+			return "", fmt.Errorf("error creating %s generation: %w", family, err)
+		}
+	}
+	return "", fmt.Errorf("error getting %s generation: %w", family, ErrCacheMiss)
+}
+
+func generationKey(family string) string {
+	return namespaceFamily + ":" + family
+}
+
+func (c *NamespacedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c *NamespacedCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return c.cache.GetWithVersion(ctx, key)
+}
+
+func (c *NamespacedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, key, value, ttl)
+}
+
+func (c *NamespacedCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Add(ctx, key, value, ttl)
+}
+
+func (c *NamespacedCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.cache.CompareAndSwap(ctx, key, value, version, ttl)
+}
+
+func (c *NamespacedCache) Delete(ctx context.Context, key string) error {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Delete(ctx, key)
+}
+
+func (c *NamespacedCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return c.cache.Increment(ctx, key, value)
+}
+
+func (c *NamespacedCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	key, err := c.key(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return c.cache.Decrement(ctx, key, value)
+}
+
+func (c *NamespacedCache) Ping() error {
+	return c.cache.Ping()
+}
+
+func (c *NamespacedCache) Close() error {
+	return c.cache.Close()
+}
+
+// Unwrap возвращает обёрнутое хранилище
+func (c *NamespacedCache) Unwrap() CacheInterface {
+	return c.cache
+}
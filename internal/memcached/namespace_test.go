@@ -0,0 +1,173 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func newTestNamespaced(t *testing.T) (*NamespacedCache, *MemoryCache) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	inner := NewMemoryCache(ctx, config.Config{})
+	return NewNamespacedCache(inner, time.Minute), inner
+}
+
+func TestNamespacedCache_Bump(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestNamespaced(t)
+
+	for _, key := range []string{"operation:1", "operation:2", "rate_limit:10.0.0.1"} {
+		if err := cache.Set(ctx, key, []byte("value"), time.Minute); err != nil {
+			t.Fatalf("ошибка записи %s: %v", key, err)
+		}
+	}
+
+	if _, err := cache.Bump(ctx, "operation"); err != nil {
+		t.Fatalf("ошибка увеличения поколения: %v", err)
+	}
+
+	for _, key := range []string{"operation:1", "operation:2"} {
+		if _, err := cache.Get(ctx, key); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: ожидалась ErrCacheMiss после Bump, получил %v", key, err)
+		}
+	}
+	if _, err := cache.Get(ctx, "rate_limit:10.0.0.1"); err != nil {
+		t.Errorf("другие семейства не должны затрагиваться: %v", err)
+	}
+
+	if err := cache.Set(ctx, "operation:1", []byte("new"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if value, err := cache.Get(ctx, "operation:1"); err != nil || string(value) != "new" {
+		t.Errorf("ожидалось новое значение, получил %q, %v", value, err)
+	}
+}
+
+func TestNamespacedCache_SharedGeneration(t *testing.T) {
+	ctx := context.Background()
+	first, inner := newTestNamespaced(t)
+	second := NewNamespacedCache(inner, time.Minute)
+
+	now := time.Now()
+	second.now = func() time.Time { return now }
+
+	if err := first.Set(ctx, "operation:1", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if _, err := second.Get(ctx, "operation:1"); err != nil {
+		t.Fatalf("экземпляры должны видеть одно поколение: %v", err)
+	}
+
+	if _, err := first.Bump(ctx, "operation"); err != nil {
+		t.Fatalf("ошибка увеличения поколения: %v", err)
+	}
+
+	// Второй экземпляр увидит новое поколение после обновления
+	now = now.Add(2 * time.Minute)
+	if _, err := second.Get(ctx, "operation:1"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("ожидалась ErrCacheMiss после обновления поколения, получил %v", err)
+	}
+}
+
+func TestNamespacedCache_EvictedGeneration(t *testing.T) {
+	ctx := context.Background()
+	cache, inner := newTestNamespaced(t)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if err := cache.Set(ctx, "operation:1", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if err := inner.Delete(ctx, generationKey("operation")); err != nil {
+		t.Fatalf("ошибка удаления поколения: %v", err)
+	}
+
+	// Вытесненный счётчик начинается заново и не возвращает старые записи
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.Get(ctx, "operation:1"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+	}
+}
+
+func TestCache_Key(t *testing.T) {
+	c := newCache(config.Config{Memcached: config.Memcached{KeyPrefix: "pdf_api"}})
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"длинный ключ", "idempotency:" + strings.Repeat("a", 300)},
+		{"пробел", "idempotency:key with spaces"},
+		{"перевод строки", "idempotency:key\r\nset x 0 0 1"},
+		{"управляющий символ", "operation:\x00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := c.key(tt.key)
+			if !legalKey(key) {
+				t.Errorf("ключ %q недопустим для memcached", key)
+			}
+			if !strings.HasPrefix(key, "pdf_api:"+KeyFamily(tt.key)+":#") {
+				t.Errorf("ожидалось семейство в ключе, получил %q", key)
+			}
+		})
+	}
+
+	t.Run("обычный ключ не меняется", func(t *testing.T) {
+		if key := c.key("operation:1"); key != "pdf_api:operation:1" {
+			t.Errorf("ожидалось pdf_api:operation:1, получил %q", key)
+		}
+	})
+
+	t.Run("разные ключи дают разные хеши", func(t *testing.T) {
+		if c.key("idempotency:a b") == c.key("idempotency:a  b") {
+			t.Errorf("хеши разных ключей совпали")
+		}
+	})
+}
+
+func TestNamespacedCache_ReplicatedBump(t *testing.T) {
+	ctx := context.Background()
+	replicated, fakes := newTestReplicated(t, 3)
+	replicated.replicas[namespaceFamily] = 2
+	cache := NewNamespacedCache(replicated, time.Minute)
+
+	key := generationKey("operation")
+	replicas := replicaServers(replicated, fakes, key)
+
+	if err := cache.Set(ctx, "operation:1", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	initial := replicas[0].value("test:" + key)
+	if initial == "" || replicas[1].value("test:"+key) != initial {
+		t.Fatalf("поколение должно быть одинаковым на копиях")
+	}
+
+	n, err := cache.Bump(ctx, "operation")
+	if err != nil {
+		t.Fatalf("ошибка увеличения поколения: %v", err)
+	}
+	for i, fake := range replicas {
+		if got := fake.value("test:" + key); got != strconv.FormatUint(n, 10) {
+			t.Errorf("на копии %d ожидалось поколение %d, получил %q", i+1, n, got)
+		}
+	}
+
+	// Без первой копии поколение продолжается со второй
+	replicas[0].stop()
+	next, err := cache.Bump(ctx, "operation")
+	if err != nil || next != n+1 {
+		t.Errorf("ожидалось поколение %d, получил %d, %v", n+1, next, err)
+	}
+}
@@ -0,0 +1,369 @@
+package memcached
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+// ReplicatedCache хранит ключи выбранных семейств на нескольких серверах memcached.
+// Копии выбираются rendezvous-хешированием, поэтому при потере сервера
+// переезжают только его ключи. Остальные семейства распределяются по серверам
+// обычным клиентом без копий.
+type ReplicatedCache struct {
+	sharded  *Cache
+	nodes    []*Cache
+	addrs    []string
+	replicas map[string]int
+	ttl      time.Duration
+}
+
+func newReplicatedCache(cfg config.Config) *ReplicatedCache {
+	servers := cfg.Memcached.Servers
+
+	c := &ReplicatedCache{
+		sharded:  newCache(cfg),
+		nodes:    make([]*Cache, len(servers)),
+		addrs:    servers,
+		replicas: make(map[string]int, len(cfg.Memcached.Replication)),
+		ttl:      time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+	}
+
+	// У каждого сервера свой клиент и свой предохранитель
+	for i, addr := range servers {
+		nodeCfg := cfg
+		nodeCfg.Memcached.Servers = []string{addr}
+		c.nodes[i] = newCache(nodeCfg)
+	}
+
+	for family, n := range cfg.Memcached.Replication {
+		c.replicas[family] = min(n, len(servers))
+	}
+
+	return c
+}
+
+// KeyFamily — часть ключа до первого двоеточия, например operation или rate_limit
+func KeyFamily(key string) string {
+	family, _, _ := strings.Cut(key, ":")
+	return family
+}
+
+// replicasFor возвращает серверы для копий ключа по убыванию веса
+// или nil, если семейство не реплицируется
+func (c *ReplicatedCache) replicasFor(key string) []*Cache {
+	n := c.replicas[KeyFamily(key)]
+	if n <= 1 {
+		return nil
+	}
+
+	type scored struct {
+		node  *Cache
+		score uint64
+	}
+	scores := make([]scored, len(c.nodes))
+	for i, node := range c.nodes {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(c.addrs[i]))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		scores[i] = scored{node: node, score: h.Sum64()}
+	}
+	slices.SortFunc(scores, func(a, b scored) int { return cmp.Compare(b.score, a.score) })
+
+	nodes := make([]*Cache, n)
+	for i := range nodes {
+		nodes[i] = scores[i].node
+	}
+	return nodes
+}
+
+// unavailable — сервер не ответил, можно перейти к следующей копии
+func unavailable(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || isNetworkError(err)
+}
+
+func (c *ReplicatedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Get(ctx, key)
+	}
+	return c.get(ctx, key, nodes)
+}
+
+// get читает с первой копии, где есть значение, и дозаписывает его
+// на копии, которые ответили промахом
+func (c *ReplicatedCache) get(ctx context.Context, key string, nodes []*Cache) ([]byte, error) {
+	var (
+		missed  []*Cache
+		lastErr error
+	)
+	for _, node := range nodes {
+		value, err := node.Get(ctx, key)
+		switch {
+		case err == nil:
+			c.repair(ctx, key, value, missed)
+			return value, nil
+		case errors.Is(err, ErrCacheMiss):
+			missed = append(missed, node)
+		case unavailable(err):
+			lastErr = err
+		default:
+			return nil, err
+		}
+	}
+
+	if len(missed) > 0 {
+		return nil, ErrCacheMiss
+	}
+	return nil, lastErr
+}
+
+// repair дозаписывает значение на копии, где его не оказалось. Остаток TTL
+// неизвестен, поэтому используется TTL по умолчанию; Add не затирает значение,
+// записанное параллельно.
+func (c *ReplicatedCache) repair(ctx context.Context, key string, value []byte, nodes []*Cache) {
+	for _, node := range nodes {
+		_ = node.Add(ctx, key, value, c.ttl)
+	}
+}
+
+// GetWithVersion читает с первой доступной копии: версия действительна только
+// для неё, и CompareAndSwap пишет туда же
+func (c *ReplicatedCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.GetWithVersion(ctx, key)
+	}
+
+	var lastErr error
+	for i, node := range nodes {
+		value, version, err := node.GetWithVersion(ctx, key)
+		if unavailable(err) {
+			lastErr = err
+			continue
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return value, version, err
+		}
+
+		// Копия могла пропустить запись, пока была недоступна
+		value, err = c.get(ctx, key, nodes[i+1:])
+		if err != nil {
+			if unavailable(err) {
+				return nil, 0, ErrCacheMiss
+			}
+			return nil, 0, err
+		}
+		if err = node.Add(ctx, key, value, c.ttl); err != nil && !errors.Is(err, ErrNotStored) {
+			return nil, 0, err
+		}
+		return node.GetWithVersion(ctx, key)
+	}
+
+	return nil, 0, lastErr
+}
+
+// Set пишет на все копии и успешен, если запись удалась хотя бы на одну
+func (c *ReplicatedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Set(ctx, key, value, ttl)
+	}
+	return c.writeAll(nodes, func(node *Cache) error {
+		return node.Set(ctx, key, value, ttl)
+	})
+}
+
+// Add выполняется на первой доступной копии, которая решает, свободен ли ключ,
+// и при успехе значение копируется на остальные
+func (c *ReplicatedCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Add(ctx, key, value, ttl)
+	}
+
+	var lastErr error
+	for i, node := range nodes {
+		err := node.Add(ctx, key, value, ttl)
+		if unavailable(err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		c.copyTo(ctx, key, value, ttl, nodes[i+1:])
+		return nil
+	}
+	return lastErr
+}
+
+// CompareAndSwap выполняется на первой доступной копии, остальные получают
+// новое значение после успешной записи
+func (c *ReplicatedCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.CompareAndSwap(ctx, key, value, version, ttl)
+	}
+
+	var lastErr error
+	for i, node := range nodes {
+		err := node.CompareAndSwap(ctx, key, value, version, ttl)
+		if unavailable(err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		c.copyTo(ctx, key, value, ttl, nodes[i+1:])
+		return nil
+	}
+	return lastErr
+}
+
+func (c *ReplicatedCache) copyTo(ctx context.Context, key string, value []byte, ttl time.Duration, nodes []*Cache) {
+	for _, node := range nodes {
+		_ = node.Set(ctx, key, value, ttl)
+	}
+}
+
+// Delete удаляет ключ со всех копий. Промах на всех копиях — ErrCacheMiss.
+func (c *ReplicatedCache) Delete(ctx context.Context, key string) error {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Delete(ctx, key)
+	}
+
+	deleted, missed := false, false
+	var lastErr error
+	for _, node := range nodes {
+		err := node.Delete(ctx, key)
+		switch {
+		case err == nil:
+			deleted = true
+		case errors.Is(err, ErrCacheMiss):
+			missed = true
+		default:
+			lastErr = err
+		}
+	}
+
+	switch {
+	case deleted:
+		return nil
+	case lastErr != nil:
+		return lastErr
+	case missed:
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+// Increment выполняется на первой доступной копии, остальные получают её
+// результат через Set. Если менять счётчик на каждой копии, копии, пропустившие
+// часть изменений, расходятся навсегда.
+func (c *ReplicatedCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Increment(ctx, key, value)
+	}
+	return c.counter(ctx, key, nodes, func(node *Cache) (uint64, error) {
+		return node.Increment(ctx, key, value)
+	})
+}
+
+func (c *ReplicatedCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	nodes := c.replicasFor(key)
+	if nodes == nil {
+		return c.sharded.Decrement(ctx, key, value)
+	}
+	return c.counter(ctx, key, nodes, func(node *Cache) (uint64, error) {
+		return node.Decrement(ctx, key, value)
+	})
+}
+
+// counter меняет счётчик на первой доступной копии и копирует результат на
+// остальные. Остаток TTL счётчика неизвестен, поэтому копии получают TTL по
+// умолчанию, как при дозаписи в repair.
+func (c *ReplicatedCache) counter(ctx context.Context, key string, nodes []*Cache, change func(*Cache) (uint64, error)) (uint64, error) {
+	var lastErr error
+	for i, node := range nodes {
+		n, err := change(node)
+		if unavailable(err) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		c.copyTo(ctx, key, []byte(strconv.FormatUint(n, 10)), c.ttl, nodes[i+1:])
+		return n, nil
+	}
+	return 0, lastErr
+}
+
+// writeAll выполняет запись на всех копиях. Ошибка возвращается, только если
+// не удалась ни одна запись, или сразу, если она не связана с доступностью.
+func (c *ReplicatedCache) writeAll(nodes []*Cache, write func(*Cache) error) error {
+	written := false
+	var lastErr error
+	for _, node := range nodes {
+		err := write(node)
+		if err == nil {
+			written = true
+			continue
+		}
+		if !unavailable(err) {
+			return err
+		}
+		lastErr = err
+	}
+
+	if !written {
+		return lastErr
+	}
+	return nil
+}
+
+// Ping успешен, если отвечает хотя бы один сервер: копии переживут потерю остальных
+func (c *ReplicatedCache) Ping() error {
+	var errs []error
+	for _, node := range c.nodes {
+		err := node.Ping()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CircuitState возвращает состояние самого доступного сервера: запросы
+// отклоняются целиком, только когда разомкнуты предохранители всех копий
+func (c *ReplicatedCache) CircuitState() CircuitState {
+	state := CircuitOpen
+	for _, node := range c.nodes {
+		state = min(state, node.CircuitState())
+	}
+	return state
+}
+
+func (c *ReplicatedCache) Close() error {
+	errs := []error{c.sharded.Close()}
+	for _, node := range c.nodes {
+		errs = append(errs, node.Close())
+	}
+	return errors.Join(errs...)
+}
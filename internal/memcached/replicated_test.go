@@ -0,0 +1,369 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+// fakeMemcached — минимальный сервер текстового протокола memcached для тестов
+type fakeMemcached struct {
+	listener net.Listener
+	mu       sync.Mutex
+	items    map[string]fakeItem
+	cas      uint64
+	conns    map[net.Conn]struct{}
+}
+
+type fakeItem struct {
+	value []byte
+	cas   uint64
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("не удалось запустить сервер: %v", err)
+	}
+
+	s := &fakeMemcached{
+		listener: listener,
+		items:    make(map[string]fakeItem),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *fakeMemcached) addr() string {
+	return s.listener.Addr().String()
+}
+
+// stop имитирует падение сервера: закрывает порт и все соединения
+func (s *fakeMemcached) stop() {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *fakeMemcached) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[key]
+	return ok
+}
+
+func (s *fakeMemcached) value(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.items[key].value)
+}
+
+func (s *fakeMemcached) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeMemcached) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		var reply string
+		switch fields[0] {
+		case "version":
+			reply = "VERSION 1.6.0\r\n"
+		case "gets", "get":
+			reply = s.get(fields[1:])
+		case "set", "add", "cas":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err = io.ReadFull(rw, data); err != nil {
+				return
+			}
+			var cas uint64
+			if fields[0] == "cas" {
+				cas, _ = strconv.ParseUint(fields[5], 10, 64)
+			}
+			reply = s.store(fields[0], fields[1], data[:size], cas)
+		case "delete":
+			reply = s.delete(fields[1])
+		case "incr", "decr":
+			delta, _ := strconv.ParseUint(fields[2], 10, 64)
+			reply = s.counter(fields[0], fields[1], delta)
+		default:
+			reply = "ERROR\r\n"
+		}
+
+		if _, err = rw.WriteString(reply); err != nil {
+			return
+		}
+		if err = rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeMemcached) get(keys []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			fmt.Fprintf(&b, "VALUE %s 0 %d %d\r\n%s\r\n", key, len(item.value), item.cas, item.value)
+		}
+	}
+	b.WriteString("END\r\n")
+	return b.String()
+}
+
+func (s *fakeMemcached) store(verb, key string, value []byte, cas uint64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.items[key]
+	switch {
+	case verb == "add" && exists:
+		return "NOT_STORED\r\n"
+	case verb == "cas" && !exists:
+		return "NOT_FOUND\r\n"
+	case verb == "cas" && item.cas != cas:
+		return "EXISTS\r\n"
+	}
+
+	s.cas++
+	s.items[key] = fakeItem{value: value, cas: s.cas}
+	return "STORED\r\n"
+}
+
+func (s *fakeMemcached) delete(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[key]; !ok {
+		return "NOT_FOUND\r\n"
+	}
+	delete(s.items, key)
+	return "DELETED\r\n"
+}
+
+func (s *fakeMemcached) counter(verb, key string, delta uint64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return "NOT_FOUND\r\n"
+	}
+	n, _ := strconv.ParseUint(string(item.value), 10, 64)
+	if verb == "incr" {
+		n += delta
+	} else {
+		n -= min(n, delta)
+	}
+
+	s.cas++
+	s.items[key] = fakeItem{value: []byte(strconv.FormatUint(n, 10)), cas: s.cas}
+	return strconv.FormatUint(n, 10) + "\r\n"
+}
+
+func newTestReplicated(t *testing.T, servers int) (*ReplicatedCache, map[string]*fakeMemcached) {
+	t.Helper()
+
+	fakes := make(map[string]*fakeMemcached, servers)
+	cfg := config.Config{Memcached: config.Memcached{
+		Enable:      true,
+		DefaultTTL:  60,
+		KeyPrefix:   "test",
+		Retry:       config.Retry{Attempts: 1},
+		Replication: map[string]int{"operation": 2},
+	}}
+	for range servers {
+		fake := newFakeMemcached(t)
+		fakes[fake.addr()] = fake
+		cfg.Memcached.Servers = append(cfg.Memcached.Servers, fake.addr())
+	}
+
+	cache, err := NewCache(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ошибка создания кэша: %v", err)
+	}
+	replicated, ok := cache.(*ReplicatedCache)
+	if !ok {
+		t.Fatalf("ожидался ReplicatedCache, получил %T", cache)
+	}
+	t.Cleanup(func() { _ = replicated.Close() })
+
+	return replicated, fakes
+}
+
+// replicaServers возвращает фейковые серверы копий ключа в порядке чтения
+func replicaServers(c *ReplicatedCache, fakes map[string]*fakeMemcached, key string) []*fakeMemcached {
+	var result []*fakeMemcached
+	for _, node := range c.replicasFor(key) {
+		for i, n := range c.nodes {
+			if n == node {
+				result = append(result, fakes[c.addrs[i]])
+			}
+		}
+	}
+	return result
+}
+
+func TestReplicatedCache_Replicas(t *testing.T) {
+	cache, fakes := newTestReplicated(t, 3)
+
+	t.Run("выбор копий устойчив", func(t *testing.T) {
+		first := replicaServers(cache, fakes, "operation:1")
+		second := replicaServers(cache, fakes, "operation:1")
+		if len(first) != 2 || first[0] != second[0] || first[1] != second[1] {
+			t.Errorf("копии ключа должны выбираться одинаково")
+		}
+	})
+
+	t.Run("rate_limit не реплицируется", func(t *testing.T) {
+		if nodes := cache.replicasFor("rate_limit:10.0.0.1"); nodes != nil {
+			t.Errorf("ожидался режим без копий, получил %d копий", len(nodes))
+		}
+	})
+}
+
+func TestReplicatedCache_Failover(t *testing.T) {
+	ctx := context.Background()
+	cache, fakes := newTestReplicated(t, 3)
+
+	key := "operation:1"
+	if err := cache.Set(ctx, key, []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+
+	replicas := replicaServers(cache, fakes, key)
+	for _, fake := range replicas {
+		if !fake.has("test:" + key) {
+			t.Fatalf("значение должно быть на каждой копии")
+		}
+	}
+
+	replicas[0].stop()
+
+	value, err := cache.Get(ctx, key)
+	if err != nil || string(value) != "value" {
+		t.Fatalf("ожидалось чтение со второй копии, получил %q, %v", value, err)
+	}
+
+	if err = cache.Add(ctx, "operation:2", []byte("new"), time.Minute); err != nil {
+		t.Errorf("Add должен работать при доступной копии: %v", err)
+	}
+}
+
+func TestReplicatedCache_ReadRepair(t *testing.T) {
+	ctx := context.Background()
+	cache, fakes := newTestReplicated(t, 3)
+
+	key := "operation:1"
+	replicas := replicaServers(cache, fakes, key)
+
+	// Значение есть только на второй копии, например первая была перезапущена
+	if err := replicas[1].store("set", "test:"+key, []byte("value"), 0); err != "STORED\r\n" {
+		t.Fatalf("ошибка записи: %s", err)
+	}
+
+	value, err := cache.Get(ctx, key)
+	if err != nil || string(value) != "value" {
+		t.Fatalf("ожидалось значение, получил %q, %v", value, err)
+	}
+	if !replicas[0].has("test:" + key) {
+		t.Errorf("значение должно быть дозаписано на первую копию")
+	}
+}
+
+func TestReplicatedCache_Update(t *testing.T) {
+	ctx := context.Background()
+	cache, fakes := newTestReplicated(t, 3)
+
+	key := "operation:1"
+	if err := cache.Add(ctx, key, []byte("1"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if err := cache.Add(ctx, key, []byte("2"), time.Minute); !errors.Is(err, ErrNotStored) {
+		t.Fatalf("ожидалась ErrNotStored, получил %v", err)
+	}
+
+	err := Update(ctx, cache, key, time.Minute, func(data []byte) ([]byte, error) {
+		return append(data, '0'), nil
+	})
+	if err != nil {
+		t.Fatalf("ошибка обновления: %v", err)
+	}
+
+	// Новое значение должно попасть на обе копии
+	replicaServers(cache, fakes, key)[0].stop()
+	value, err := cache.Get(ctx, key)
+	if err != nil || string(value) != "10" {
+		t.Errorf("ожидалось 10 на второй копии, получил %q, %v", value, err)
+	}
+}
+
+func TestReplicatedCache_Counter(t *testing.T) {
+	ctx := context.Background()
+	cache, fakes := newTestReplicated(t, 3)
+
+	key := "operation:counter"
+	replicas := replicaServers(cache, fakes, key)
+
+	// Вторая копия пропустила прежние изменения счётчика
+	if err := replicas[0].store("set", "test:"+key, []byte("5"), 0); err != "STORED\r\n" {
+		t.Fatalf("ошибка записи: %s", err)
+	}
+
+	n, err := cache.Increment(ctx, key, 1)
+	if err != nil || n != 6 {
+		t.Fatalf("ожидалось 6, получил %d, %v", n, err)
+	}
+	for i, fake := range replicas {
+		if got := fake.value("test:" + key); got != "6" {
+			t.Errorf("на копии %d ожидалось 6, получил %q", i+1, got)
+		}
+	}
+
+	// После потери первой копии счётчик продолжается со скопированного значения
+	replicas[0].stop()
+	if n, err = cache.Decrement(ctx, key, 2); err != nil || n != 4 {
+		t.Errorf("ожидалось 4, получил %d, %v", n, err)
+	}
+}
@@ -0,0 +1,126 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Значения по умолчанию, если в конфиге не заданы
+const (
+	defaultRetryAttempts      = 3
+	defaultRetryBaseDelay     = 20 * time.Millisecond
+	defaultRetryMaxDelay      = 200 * time.Millisecond
+	defaultBreakerFailures    = 5
+	defaultBreakerOpenTimeout = 10 * time.Second
+)
+
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+func newRetryPolicy(cfg config.Retry) retryPolicy {
+	p := retryPolicy{
+		attempts:  cfg.Attempts,
+		baseDelay: time.Duration(cfg.BaseDelay) * time.Millisecond,
+		maxDelay:  time.Duration(cfg.MaxDelay) * time.Millisecond,
+	}
+	if p.attempts <= 0 {
+		p.attempts = defaultRetryAttempts
+	}
+	if p.baseDelay <= 0 {
+		p.baseDelay = defaultRetryBaseDelay
+	}
+	if p.maxDelay < p.baseDelay {
+		p.maxDelay = max(defaultRetryMaxDelay, p.baseDelay)
+	}
+	return p
+}
+
+// delay растёт вдвое с каждой попыткой, случайный разброс в половину
+// задержки не даёт клиентам повторять запросы одновременно
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.baseDelay << (attempt - 1)
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// do выполняет запрос через предохранитель и повторяет его при сетевых сбоях.
+// Неидемпотентные запросы (Add, CAS, счётчики) повторяются, только если
+// соединение не было установлено и запрос точно не дошёл до сервера.
+func (c *Cache) do(ctx context.Context, idempotent bool, request func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			return err
+		}
+
+		err := request()
+		failed := isNetworkError(err)
+		c.breaker.done(failed)
+
+		if !failed || attempt >= c.retry.attempts || !(idempotent || isDialError(err)) {
+			return err
+		}
+
+		metrics.UpdateRetryAttempts()
+		timer := time.NewTimer(c.retry.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// isNetworkError отличает недоступность memcached от штатных ответов вроде промаха
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var (
+		connectErr *memcache.ConnectTimeoutError
+		netErr     net.Error
+	)
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// IsUnavailable сообщает, что хранилище недоступно: сетевая ошибка, нет
+// серверов или разомкнут предохранитель. Остальные ошибки означают, что
+// хранилище ответило, и пропускать запрос мимо ограничений нельзя.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, memcache.ErrNoServers) ||
+		isNetworkError(err)
+}
+
+// isDialError — соединение не установлено, запрос на сервер не отправлялся
+func isDialError(err error) bool {
+	var (
+		connectErr *memcache.ConnectTimeoutError
+		opErr      *net.OpError
+	)
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
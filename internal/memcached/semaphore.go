@@ -0,0 +1,206 @@
+package memcached
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSemaphoreFull = errors.New("semaphore is full")
+
+// SemaphoreFullError сообщает, через сколько истечёт ближайшая аренда.
+// Это верхняя граница: обычно слот освобождают раньше.
+type SemaphoreFullError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *SemaphoreFullError) Error() string {
+	return fmt.Sprintf("semaphore %s is full, retry after %s", e.Key, e.RetryAfter)
+}
+
+func (e *SemaphoreFullError) Is(target error) bool {
+	return target == ErrSemaphoreFull
+}
+
+// Lease — занятый слот семафора
+type Lease struct {
+	Key string
+	ID  string
+	// Сколько слотов занято после захвата, по данным хранилища
+	InUse int
+}
+
+// Semaphore — распределённый семафор поверх кэша. Все аренды ключа хранятся
+// в одном значении и меняются через CompareAndSwap. У аренды есть срок:
+// слоты упавшего экземпляра освобождаются сами.
+type Semaphore struct {
+	cache CacheInterface
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+type semaphoreState struct {
+	// ID аренды → окончание срока в наносекундах Unix
+	Leases map[string]int64 `json:"leases"`
+}
+
+func NewSemaphore(cache CacheInterface, ttl time.Duration) *Semaphore {
+	return &Semaphore{
+		cache: cache,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Acquire занимает слот key, если занято меньше capacity.
+// При нехватке слотов возвращает *SemaphoreFullError.
+func (s *Semaphore) Acquire(ctx context.Context, key string, capacity int) (Lease, error) {
+	id, err := leaseID()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	var lease Lease
+	err = s.update(ctx, key, func(state *semaphoreState, now int64) error {
+		if len(state.Leases) >= capacity {
+			earliest := int64(0)
+			for _, expires := range state.Leases {
+				if earliest == 0 || expires < earliest {
+					earliest = expires
+				}
+			}
+			return &SemaphoreFullError{Key: key, RetryAfter: time.Duration(max(earliest-now, 0))}
+		}
+
+		state.Leases[id] = now + int64(s.ttl)
+		lease = Lease{Key: key, ID: id, InUse: len(state.Leases)}
+		return nil
+	})
+	if err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+// Release освобождает слот и возвращает, сколько слотов осталось занято
+func (s *Semaphore) Release(ctx context.Context, lease Lease) (int, error) {
+	inUse := 0
+	err := s.update(ctx, lease.Key, func(state *semaphoreState, _ int64) error {
+		delete(state.Leases, lease.ID)
+		inUse = len(state.Leases)
+		return nil
+	})
+	if errors.Is(err, ErrCacheMiss) {
+		// Значение истекло вместе со всеми арендами
+		return 0, nil
+	}
+	return inUse, err
+}
+
+// Count возвращает число действующих аренд key на всех экземплярах
+func (s *Semaphore) Count(ctx context.Context, key string) (int, error) {
+	data, err := s.cache.Get(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting semaphore %s: %w", key, err)
+	}
+
+	var state semaphoreState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("error decoding semaphore %s: %w", key, err)
+	}
+
+	now := s.now().UnixNano()
+	count := 0
+	for _, expires := range state.Leases {
+		if expires > now {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// update читает аренды, убирает истёкшие и записывает результат change.
+// Отсутствующее значение создаётся через Add, поэтому Release на пустом
+// ключе возвращает ErrCacheMiss, а не создаёт его.
+func (s *Semaphore) update(ctx context.Context, key string, change func(state *semaphoreState, now int64) error) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		data, version, err := s.cache.GetWithVersion(ctx, key)
+		exists := err == nil
+		if err != nil && !errors.Is(err, ErrCacheMiss) {
+			return fmt.Errorf("error getting semaphore %s: %w", key, err)
+		}
+
+		state := semaphoreState{}
+		if exists {
+			if err = json.Unmarshal(data, &state); err != nil {
+				return fmt.Errorf("error decoding semaphore %s: %w", key, err)
+			}
+		}
+		if state.Leases == nil {
+			state.Leases = make(map[string]int64)
+		}
+
+		now := s.now().UnixNano()
+		latest := now
+		for id, expires := range state.Leases {
+			if expires <= now {
+				delete(state.Leases, id)
+			}
+		}
+
+		if err = change(&state, now); err != nil {
+			return err
+		}
+		if !exists && len(state.Leases) == 0 {
+			return ErrCacheMiss
+		}
+
+		for _, expires := range state.Leases {
+			latest = max(latest, expires)
+		}
+		value, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("error encoding semaphore %s: %w", key, err)
+		}
+
+		// Значение живёт, пока жива самая поздняя аренда
+		ttl := time.Duration(latest-now) + time.Second
+		if exists {
+			err = s.cache.CompareAndSwap(ctx, key, value, version, ttl)
+		} else {
+			err = s.cache.Add(ctx, key, value, ttl)
+		}
+
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, ErrCASConflict), errors.Is(err, ErrNotStored), errors.Is(err, ErrCacheMiss):
+			// Даём конкурирующему писателю завершиться
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt+1) * time.Millisecond):
+			}
+		default:
+			return fmt.Errorf("error saving semaphore %s: %w", key, err)
+		}
+	}
+
+	return ErrCASConflict
+}
+
+func leaseID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating lease id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,113 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func newTestSemaphore(t *testing.T) (*Semaphore, *time.Time) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := NewSemaphore(NewMemoryCache(ctx, config.Config{}), time.Minute)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestSemaphore_Acquire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("не больше capacity", func(t *testing.T) {
+		s, _ := newTestSemaphore(t)
+
+		for i := 0; i < 2; i++ {
+			lease, err := s.Acquire(ctx, "concurrency:test", 2)
+			if err != nil {
+				t.Fatalf("слот %d должен был заняться, получил %v", i+1, err)
+			}
+			if lease.InUse != i+1 {
+				t.Errorf("ожидалось %d занятых слотов, получил %d", i+1, lease.InUse)
+			}
+		}
+
+		_, err := s.Acquire(ctx, "concurrency:test", 2)
+		var full *SemaphoreFullError
+		if !errors.As(err, &full) || !errors.Is(err, ErrSemaphoreFull) {
+			t.Fatalf("ожидалась SemaphoreFullError, получил %v", err)
+		}
+		if full.RetryAfter != time.Minute {
+			t.Errorf("ожидался повтор через минуту, получил %v", full.RetryAfter)
+		}
+	})
+
+	t.Run("освобождённый слот занимается снова", func(t *testing.T) {
+		s, _ := newTestSemaphore(t)
+
+		lease, err := s.Acquire(ctx, "concurrency:test", 1)
+		if err != nil {
+			t.Fatalf("ошибка захвата: %v", err)
+		}
+		if inUse, err := s.Release(ctx, lease); err != nil || inUse != 0 {
+			t.Fatalf("ожидалось 0 занятых слотов, получил %d, %v", inUse, err)
+		}
+		if _, err = s.Acquire(ctx, "concurrency:test", 1); err != nil {
+			t.Errorf("слот должен был освободиться, получил %v", err)
+		}
+	})
+
+	t.Run("истёкшая аренда освобождает слот", func(t *testing.T) {
+		s, now := newTestSemaphore(t)
+
+		if _, err := s.Acquire(ctx, "concurrency:test", 1); err != nil {
+			t.Fatalf("ошибка захвата: %v", err)
+		}
+
+		// Экземпляр упал, не освободив слот
+		*now = now.Add(time.Minute + time.Second)
+		if _, err := s.Acquire(ctx, "concurrency:test", 1); err != nil {
+			t.Errorf("истёкшая аренда не должна занимать слот, получил %v", err)
+		}
+	})
+
+	t.Run("освобождение без значения", func(t *testing.T) {
+		s, _ := newTestSemaphore(t)
+
+		if _, err := s.Release(ctx, Lease{Key: "concurrency:test", ID: "1"}); err != nil {
+			t.Errorf("ожидался nil, получил %v", err)
+		}
+	})
+}
+
+func TestSemaphore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSemaphore(t)
+
+	var (
+		wg       sync.WaitGroup
+		acquired atomic.Int64
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Acquire(ctx, "concurrency:test", 5); err == nil {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Часть горутин может исчерпать попытки CAS, но лишних слотов быть не должно
+	if n := acquired.Load(); n == 0 || n > 5 {
+		t.Errorf("ожидалось от 1 до 5 занятых слотов, получил %d", n)
+	}
+}
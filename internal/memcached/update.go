@@ -0,0 +1,41 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Сколько раз Update перечитывает значение при конфликте версий
+const maxUpdateAttempts = 10
+
+// Update выполняет read-modify-write через GetWithVersion и CompareAndSwap.
+// Если значение изменили между чтением и записью, change вызывается заново
+// с новым значением. После maxUpdateAttempts конфликтов возвращает ErrCASConflict.
+func Update(ctx context.Context, cache CacheInterface, key string, ttl time.Duration, change func(value []byte) ([]byte, error)) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		value, version, err := cache.GetWithVersion(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		updated, err := change(value)
+		if err != nil {
+			return err
+		}
+
+		err = cache.CompareAndSwap(ctx, key, updated, version, ttl)
+		if !errors.Is(err, ErrCASConflict) {
+			return err
+		}
+
+		// Даём конкурирующему писателю завершиться
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Millisecond):
+		}
+	}
+
+	return ErrCASConflict
+}
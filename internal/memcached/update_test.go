@@ -0,0 +1,109 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func TestMemoryCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	c := newTestMemoryCache(t, config.Memory{})
+
+	_ = c.Set(ctx, "key", []byte("v1"), time.Minute)
+	_, version, err := c.GetWithVersion(ctx, "key")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	t.Run("Запись с актуальной версией", func(t *testing.T) {
+		if err := c.CompareAndSwap(ctx, "key", []byte("v2"), version, time.Minute); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+	})
+
+	t.Run("Устаревшая версия отклоняется", func(t *testing.T) {
+		if err := c.CompareAndSwap(ctx, "key", []byte("v3"), version, time.Minute); !errors.Is(err, ErrCASConflict) {
+			t.Errorf("ожидалась ErrCASConflict, получил %v", err)
+		}
+		if got, _ := c.Get(ctx, "key"); string(got) != "v2" {
+			t.Errorf("ожидалось v2, получил %q", got)
+		}
+	})
+
+	t.Run("Исчезнувший ключ", func(t *testing.T) {
+		if err := c.CompareAndSwap(ctx, "missing", []byte("v"), 1, time.Minute); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Параллельные обновления не теряются", func(t *testing.T) {
+		c := newTestMemoryCache(t, config.Memory{})
+		_ = c.Set(ctx, "counter", []byte("0"), 0)
+
+		const writers = 5
+
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := Update(ctx, c, "counter", 0, func(value []byte) ([]byte, error) {
+					n, _ := strconv.Atoi(string(value))
+					// Расширяем окно между чтением и записью, чтобы вызвать конфликты
+					time.Sleep(time.Millisecond)
+					return []byte(strconv.Itoa(n + 1)), nil
+				})
+				if err != nil && !errors.Is(err, ErrCASConflict) {
+					t.Errorf("неожиданная ошибка: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, _ := c.Get(ctx, "counter")
+		n, _ := strconv.Atoi(string(got))
+		if n == 0 || n > writers {
+			t.Errorf("ожидалось от 1 до %d обновлений, получил %d", writers, n)
+		}
+	})
+
+	t.Run("Конфликт приводит к повторному чтению", func(t *testing.T) {
+		c := newTestMemoryCache(t, config.Memory{})
+		_ = c.Set(ctx, "key", []byte("a"), 0)
+
+		calls := 0
+		err := Update(ctx, c, "key", 0, func(value []byte) ([]byte, error) {
+			calls++
+			if calls == 1 {
+				// Другой писатель успел изменить значение
+				_ = c.Set(ctx, "key", []byte("b"), 0)
+			}
+			return append(value, '!'), nil
+		})
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+
+		if got, _ := c.Get(ctx, "key"); string(got) != "b!" || calls != 2 {
+			t.Errorf("ожидалось b! за 2 вызова, получил %q за %d", got, calls)
+		}
+	})
+
+	t.Run("Отсутствующий ключ", func(t *testing.T) {
+		c := newTestMemoryCache(t, config.Memory{})
+		err := Update(ctx, c, "missing", 0, func(value []byte) ([]byte, error) { return value, nil })
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+}
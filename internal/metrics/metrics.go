@@ -111,19 +111,41 @@ var (
 		Help:      "Счётчик статусов операций (NEW, PROGRESS, DONE, ERROR)",
 	}, []string{"status"})
 
-	// Количество успешных обращений к Memcached
-	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+	// Количество успешных обращений к кэшу по семействам ключей
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "cache_hits",
-		Help:      "Количество успешных обращений к Memcached",
-	})
+		Help:      "Количество успешных обращений к кэшу по семействам ключей",
+	}, []string{"family"})
 
-	// Количество пропущенных записей в Memcached.
-	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+	// Количество пропущенных записей в кэше по семействам ключей
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "cache_misses",
-		Help:      "Количество пропущенных записей в Memcached",
-	})
+		Help:      "Количество пропущенных записей в кэше по семействам ключей",
+	}, []string{"family"})
+
+	// Время выполнения операций с кэшем (в секундах)
+	cacheOperationDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cache_operation_duration_seconds",
+		Help:      "Время выполнения операций с кэшем (в секундах)",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "family", "result"})
+
+	// Занятые слоты распределённого ограничения одновременных загрузок и обработок
+	concurrencySlotsInUse = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "concurrency_slots_in_use",
+		Help:      "Занятые слоты одновременных загрузок и обработок на всех экземплярах",
+	}, []string{"scope"})
+
+	// Состояние предохранителя memcached: 0 — замкнут, 1 — пробный запрос, 2 — разомкнут
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "memcached_circuit_breaker_state",
+		Help:      "Состояние предохранителя memcached: 0 — замкнут, 1 — пробный запрос, 2 — разомкнут",
+	}, []string{"server"})
 
 	// Количество превышений лимита запросов
 	rateLimitExceededCount = promauto.NewCounter(prometheus.CounterOpts{
@@ -182,25 +204,25 @@ var (
 	})
 
 	// Количество успешных экспортов отчётов
-	exportSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
+	exportSuccessCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "export_success_count",
-		Help:      "Количество успешных экспортов отчётов",
-	})
+		Help:      "Количество успешных экспортов отчётов по форматам (csv, pdf)",
+	}, []string{"format"})
 
 	// Количество ошибок при экспорте отчётов
-	exportErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+	exportErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "export_error_count",
-		Help:      "Количество ошибок при экспорте отчётов",
-	})
+		Help:      "Количество ошибок при экспорте отчётов по форматам (csv, pdf)",
+	}, []string{"format"})
 
 	// Время выполнения экспорта отчётов (в секундах)
-	exportTimeSeconds = promauto.NewSummary(prometheus.SummaryOpts{
+	exportTimeSeconds = promauto.NewSummaryVec(prometheus.SummaryOpts{
 		Namespace: namespace,
 		Name:      "export_time_seconds",
-		Help:      "Время выполнения экспорта отчётов (в секундах)",
-	})
+		Help:      "Время выполнения экспорта отчётов по форматам (в секундах)",
+	}, []string{"format"})
 )
 
 // UpdateFileProcessingTime обновляет время обработки файла
@@ -263,14 +285,29 @@ func UpdateOperationStatus(status string) {
 	operationStatusCounts.WithLabelValues(status).Inc()
 }
 
-// UpdateCacheHits увеличивает счётчик успешных обращений к Memcached
-func UpdateCacheHits() {
-	cacheHits.Inc()
+// UpdateCacheHits увеличивает счётчик успешных обращений к кэшу
+func UpdateCacheHits(family string) {
+	cacheHits.WithLabelValues(family).Inc()
+}
+
+// UpdateCacheMisses увеличивает счётчик пропущенных записей в кэше
+func UpdateCacheMisses(family string) {
+	cacheMisses.WithLabelValues(family).Inc()
+}
+
+// UpdateCacheOperationTime обновляет время выполнения операции с кэшем
+func UpdateCacheOperationTime(operation, family, result string, duration float64) {
+	cacheOperationDurationSeconds.WithLabelValues(operation, family, result).Observe(duration)
+}
+
+// UpdateConcurrencySlots обновляет число занятых слотов: upload или processing
+func UpdateConcurrencySlots(scope string, inUse float64) {
+	concurrencySlotsInUse.WithLabelValues(scope).Set(inUse)
 }
 
-// UpdateCacheMisses увеличивает счётчик пропущенных записей в Memcached
-func UpdateCacheMisses() {
-	cacheMisses.Inc()
+// UpdateCircuitBreakerState обновляет состояние предохранителя memcached
+func UpdateCircuitBreakerState(server string, state float64) {
+	circuitBreakerState.WithLabelValues(server).Set(state)
 }
 
 // UpdateRateLimitExceeded увеличивает счётчик превышений лимита запросов
@@ -313,19 +350,19 @@ func UpdateComparisonTime(duration float64) {
 	comparisonTimeSeconds.Observe(duration)
 }
 
-// UpdateExportSuccess увеличивает счётчик успешных экспортов отчётов
-func UpdateExportSuccess() {
-	exportSuccessCount.Inc()
+// UpdateExportSuccess увеличивает счётчик успешных экспортов отчётов в формате format
+func UpdateExportSuccess(format string) {
+	exportSuccessCount.WithLabelValues(format).Inc()
 }
 
-// UpdateExportError увеличивает счётчик ошибок при экспорте отчётов
-func UpdateExportError() {
-	exportErrorCount.Inc()
+// UpdateExportError увеличивает счётчик ошибок при экспорте отчётов в формате format
+func UpdateExportError(format string) {
+	exportErrorCount.WithLabelValues(format).Inc()
 }
 
-// UpdateExportTime обновляет время выполнения экспорта отчётов
-func UpdateExportTime(duration float64) {
-	exportTimeSeconds.Observe(duration)
+// UpdateExportTime обновляет время выполнения экспорта отчётов в формате format
+func UpdateExportTime(format string, duration float64) {
+	exportTimeSeconds.WithLabelValues(format).Observe(duration)
 }
 
 // InitMetricsOn подготавливает и вешает /metrics на HTTP роутер без горутин
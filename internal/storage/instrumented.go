@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Caritas-Team/reviewer/internal/storage"
+
+// Семейства ключей — часть ключа до первого двоеточия. Остальные ключи
+// попадают в familyOther, чтобы не раздувать число меток в метриках.
+var families = map[string]bool{
+	"rate_limit":  true,
+	"operation":   true,
+	"batch":       true,
+	"idempotency": true,
+	"extraction":  true,
+	"concurrency": true,
+}
+
+const familyOther = "other"
+
+// Результаты операций для метрик и спанов
+const (
+	resultOK        = "ok"
+	resultMiss      = "miss"
+	resultNotStored = "not_stored"
+	resultConflict  = "conflict"
+	resultRejected  = "circuit_open"
+	resultError     = "error"
+)
+
+// InstrumentedCache — обёртка над кэшем, которая считает попадания и промахи
+// по семействам ключей, замеряет время операций и открывает спан на каждый вызов
+type InstrumentedCache struct {
+	cache   memcached.CacheInterface
+	backend string
+	tracer  trace.Tracer
+}
+
+// Instrument оборачивает cache; backend попадает в атрибуты спанов
+func Instrument(cache memcached.CacheInterface, backend string) *InstrumentedCache {
+	return &InstrumentedCache{
+		cache:   cache,
+		backend: backend,
+		tracer:  otel.Tracer(tracerName),
+	}
+}
+
+func (c *InstrumentedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, done := c.start(ctx, "Get", key, true)
+	value, err := c.cache.Get(ctx, key)
+	done(err)
+	return value, err
+}
+
+func (c *InstrumentedCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	ctx, done := c.start(ctx, "GetWithVersion", key, true)
+	value, version, err := c.cache.GetWithVersion(ctx, key)
+	done(err)
+	return value, version, err
+}
+
+func (c *InstrumentedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, done := c.start(ctx, "Set", key, false)
+	err := c.cache.Set(ctx, key, value, ttl)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, done := c.start(ctx, "Add", key, false)
+	err := c.cache.Add(ctx, key, value, ttl)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	ctx, done := c.start(ctx, "CompareAndSwap", key, false)
+	err := c.cache.CompareAndSwap(ctx, key, value, version, ttl)
+	done(err)
+	return err
+}
+
+func (c *InstrumentedCache) Delete(ctx context.Context, key string) error {
+	ctx, done := c.start(ctx, "Delete", key, false)
+	err := c.cache.Delete(ctx, key)
+	done(err)
+	return err
+}
+
+// Increment и Decrement читают счётчик, поэтому тоже считаются попаданием или промахом
+func (c *InstrumentedCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	ctx, done := c.start(ctx, "Increment", key, true)
+	n, err := c.cache.Increment(ctx, key, value)
+	done(err)
+	return n, err
+}
+
+func (c *InstrumentedCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	ctx, done := c.start(ctx, "Decrement", key, true)
+	n, err := c.cache.Decrement(ctx, key, value)
+	done(err)
+	return n, err
+}
+
+func (c *InstrumentedCache) Ping() error {
+	return c.cache.Ping()
+}
+
+func (c *InstrumentedCache) Close() error {
+	return c.cache.Close()
+}
+
+// Unwrap возвращает обёрнутое хранилище, например для memcached.CircuitStateOf
+func (c *InstrumentedCache) Unwrap() memcached.CacheInterface {
+	return c.cache
+}
+
+// start открывает спан операции и возвращает функцию, которая его закрывает
+// и записывает метрики. Для чтений учитываются попадания и промахи.
+func (c *InstrumentedCache) start(ctx context.Context, operation, key string, read bool) (context.Context, func(error)) {
+	family := keyFamily(key)
+	started := time.Now()
+
+	ctx, span := c.tracer.Start(ctx, "cache."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cache.backend", c.backend),
+			attribute.String("cache.family", family),
+		),
+	)
+
+	return ctx, func(err error) {
+		result := resultOf(err)
+
+		metrics.UpdateCacheOperationTime(operation, family, result, time.Since(started).Seconds())
+		if read {
+			switch result {
+			case resultOK:
+				metrics.UpdateCacheHits(family)
+			case resultMiss:
+				metrics.UpdateCacheMisses(family)
+			}
+		}
+
+		span.SetAttributes(attribute.String("cache.result", result))
+		// Промах и конфликт — штатные исходы, ошибкой спан помечаем только при сбое
+		// или отказе предохранителя
+		if result == resultError || result == resultRejected {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func keyFamily(key string) string {
+	family := memcached.KeyFamily(key)
+	if !families[family] {
+		return familyOther
+	}
+	return family
+}
+
+func resultOf(err error) string {
+	switch {
+	case err == nil:
+		return resultOK
+	case errors.Is(err, memcached.ErrCacheMiss):
+		return resultMiss
+	case errors.Is(err, memcached.ErrNotStored):
+		return resultNotStored
+	case errors.Is(err, memcached.ErrCASConflict):
+		return resultConflict
+	case errors.Is(err, memcached.ErrCircuitOpen):
+		return resultRejected
+	default:
+		return resultError
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestInstrumented(t *testing.T) (*InstrumentedCache, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	cache := Instrument(memcached.NewMemoryCache(ctx, config.Config{}), KindMemory)
+	cache.tracer = provider.Tracer(tracerName)
+	return cache, recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestInstrumentedCache_Spans(t *testing.T) {
+	ctx := context.Background()
+	cache, recorder := newTestInstrumented(t)
+
+	if err := cache.Set(ctx, "operation:1", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if _, err := cache.Get(ctx, "rate_limit:10.0.0.1"); !errors.Is(err, memcached.ErrCacheMiss) {
+		t.Fatalf("ожидалась ErrCacheMiss, получил %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ожидалось 2 спана, получил %d", len(spans))
+	}
+
+	tests := []struct {
+		name, family, result string
+	}{
+		{"cache.Set", "operation", resultOK},
+		{"cache.Get", "rate_limit", resultMiss},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("ожидался спан %s, получил %s", tt.name, span.Name())
+		}
+		if family := spanAttribute(span, "cache.family"); family != tt.family {
+			t.Errorf("%s: ожидалось семейство %s, получил %s", tt.name, tt.family, family)
+		}
+		if result := spanAttribute(span, "cache.result"); result != tt.result {
+			t.Errorf("%s: ожидался результат %s, получил %s", tt.name, tt.result, result)
+		}
+		// Промах не считается ошибкой
+		if span.Status().Code == codes.Error {
+			t.Errorf("%s: спан не должен быть помечен ошибкой", tt.name)
+		}
+	}
+}
+
+func TestInstrumentedCache_Error(t *testing.T) {
+	ctx := context.Background()
+	cache, recorder := newTestInstrumented(t)
+
+	if err := cache.Set(ctx, "idempotency:key", []byte("text"), time.Minute); err != nil {
+		t.Fatalf("ошибка записи: %v", err)
+	}
+	if _, err := cache.Increment(ctx, "idempotency:key", 1); err == nil {
+		t.Fatal("ожидалась ошибка увеличения нечислового значения")
+	}
+
+	spans := recorder.Ended()
+	span := spans[len(spans)-1]
+	if span.Status().Code != codes.Error {
+		t.Errorf("спан со сбоем должен быть помечен ошибкой")
+	}
+}
+
+func TestKeyFamily(t *testing.T) {
+	tests := map[string]string{
+		"rate_limit:10.0.0.1": "rate_limit",
+		"operation:id":        "operation",
+		"idempotency:key":     "idempotency",
+		"batch:id":            "batch",
+		"unknown:id":          familyOther,
+		"plain":               familyOther,
+	}
+
+	for key, want := range tests {
+		if got := keyFamily(key); got != want {
+			t.Errorf("keyFamily(%q) = %s, ожидалось %s", key, got, want)
+		}
+	}
+}
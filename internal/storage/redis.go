@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/redis/go-redis/v9"
+)
+
+// Версия значения для CompareAndSwap хранится в соседнем ключе с тем же сроком жизни.
+// Каждая запись идёт через скрипт, чтобы значение и версия менялись атомарно.
+const bumpVersion = `
+local function bump(key, versionKey)
+	redis.call('INCR', versionKey)
+	local ttl = redis.call('PTTL', key)
+	if ttl > 0 then
+		redis.call('PEXPIRE', versionKey, ttl)
+	else
+		redis.call('PERSIST', versionKey)
+	end
+end
+
+local function store(key, value, ttl)
+	if tonumber(ttl) > 0 then
+		redis.call('SET', key, value, 'PX', ttl)
+	else
+		redis.call('SET', key, value)
+	end
+end
+`
+
+var setScript = redis.NewScript(bumpVersion + `
+store(KEYS[1], ARGV[1], ARGV[2])
+bump(KEYS[1], KEYS[2])
+return 1
+`)
+
+var addScript = redis.NewScript(bumpVersion + `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+store(KEYS[1], ARGV[1], ARGV[2])
+bump(KEYS[1], KEYS[2])
+return 1
+`)
+
+// casScript возвращает -1, если ключа нет, и 0 при несовпадении версии
+var casScript = redis.NewScript(bumpVersion + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+if (redis.call('GET', KEYS[2]) or '0') ~= ARGV[3] then
+	return 0
+end
+store(KEYS[1], ARGV[1], ARGV[2])
+bump(KEYS[1], KEYS[2])
+return 1
+`)
+
+// counterScript — INCRBY с PEXPIRE для нового ключа. Скрипт выполняется атомарно,
+// поэтому ключ не останется без срока, если соединение оборвётся между командами.
+// При floor = 1 значение не опускается ниже нуля, как у decr в memcached.
+var counterScript = redis.NewScript(bumpVersion + `
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if ARGV[3] == '1' and n < 0 then
+	redis.call('SET', KEYS[1], 0, 'KEEPTTL')
+	n = 0
+end
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+bump(KEYS[1], KEYS[2])
+return n
+`)
+
+// RedisCache — кэш в Redis или совместимом по протоколу RESP сервере
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+}
+
+func NewRedisCache(ctx context.Context, cfg config.Config) (*RedisCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Redis.Addr,
+		Username:    cfg.Redis.Username,
+		Password:    cfg.Redis.Password,
+		DB:          cfg.Redis.DB,
+		DialTimeout: time.Duration(cfg.Redis.DialTimeout) * time.Second,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("error connecting to redis: %w", err)
+	}
+
+	return &RedisCache{
+		client: client,
+		ttl:    time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		prefix: cfg.Redis.KeyPrefix,
+	}, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, memcached.ErrCacheMiss
+	}
+	return value, err
+}
+
+func (c *RedisCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	values, err := c.client.MGet(ctx, c.key(key), c.versionKey(key)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	value, ok := values[0].(string)
+	if !ok {
+		return nil, 0, memcached.ErrCacheMiss
+	}
+
+	// Значение без версии ещё ни разу не перезаписывалось через этот кэш
+	var version uint64
+	if v, ok := values[1].(string); ok {
+		if version, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("error parsing value version: %w", err)
+		}
+	}
+
+	return []byte(value), version, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return setScript.Run(ctx, c.client, c.keys(key), value, expiration(ttl).Milliseconds()).Err()
+}
+
+// Add записывает значение, только если ключа ещё нет, иначе возвращает ErrNotStored
+func (c *RedisCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	stored, err := addScript.Run(ctx, c.client, c.keys(key), value, expiration(ttl).Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if stored == 0 {
+		return memcached.ErrNotStored
+	}
+	return nil
+}
+
+func (c *RedisCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	result, err := casScript.Run(ctx, c.client, c.keys(key), value, expiration(ttl).Milliseconds(),
+		strconv.FormatUint(version, 10)).Int()
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case -1:
+		return memcached.ErrCacheMiss
+	case 0:
+		return memcached.ErrCASConflict
+	default:
+		return nil
+	}
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	var deleted *redis.IntCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, c.key(key))
+		pipe.Del(ctx, c.versionKey(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return memcached.ErrCacheMiss
+	}
+	return nil
+}
+
+// Increment увеличивает число под ключом. Отсутствующий ключ создаётся со значением
+// value и TTL по умолчанию
+func (c *RedisCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	if value > uint64(1<<63-1) {
+		return 0, memcached.ErrNotNumeric
+	}
+	return c.counter(ctx, key, int64(value), false)
+}
+
+// Decrement уменьшает число под ключом, не опускаясь ниже нуля
+func (c *RedisCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	if value > uint64(1<<63-1) {
+		return 0, memcached.ErrNotNumeric
+	}
+	return c.counter(ctx, key, -int64(value), true)
+}
+
+func (c *RedisCache) counter(ctx context.Context, key string, delta int64, floor bool) (uint64, error) {
+	floorArg := "0"
+	if floor {
+		floorArg = "1"
+	}
+
+	n, err := counterScript.Run(ctx, c.client, c.keys(key), delta, c.ttl.Milliseconds(), floorArg).Int64()
+	if err != nil {
+		if strings.Contains(err.Error(), "not an integer") {
+			return 0, memcached.ErrNotNumeric
+		}
+		return 0, err
+	}
+	if n < 0 {
+		return 0, memcached.ErrNotNumeric
+	}
+	return uint64(n), nil
+}
+
+func (c *RedisCache) Ping() error {
+	return c.client.Ping(context.Background()).Err()
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+// key — ключ значения. Имя ключа в фигурных скобках — hash tag: Redis Cluster
+// кладёт значение и его версию в один слот, иначе скрипты с обоими ключами
+// завершались бы ошибкой CROSSSLOT.
+func (c *RedisCache) key(key string) string {
+	if c.prefix == "" {
+		return "{" + key + "}"
+	}
+	return c.prefix + ":{" + key + "}"
+}
+
+func (c *RedisCache) versionKey(key string) string {
+	return c.key(key) + ":version"
+}
+
+// keys — ключ значения и ключ его версии для скриптов
+func (c *RedisCache) keys(key string) []string {
+	return []string{c.key(key), c.versionKey(key)}
+}
+
+// expiration переводит TTL в срок для SET: ttl <= 0 — без срока, как в memcached.
+// Доли миллисекунды округляются вверх, иначе Redis отклонит нулевой PX.
+func expiration(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return 0
+	}
+	return max(ttl, time.Millisecond)
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache(context.Background(), config.Config{
+		Memcached: config.Memcached{DefaultTTL: 30},
+		Redis:     config.Redis{Addr: server.Addr(), KeyPrefix: "test"},
+	})
+	if err != nil {
+		t.Fatalf("не удалось подключиться к redis: %v", err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+
+	return cache, server
+}
+
+func TestRedisCache_GetSet(t *testing.T) {
+	ctx := context.Background()
+	cache, server := newTestRedis(t)
+
+	t.Run("Отсутствующий ключ", func(t *testing.T) {
+		if _, err := cache.Get(ctx, "missing"); !errors.Is(err, memcached.ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+
+	t.Run("Значение пишется с префиксом и TTL", func(t *testing.T) {
+		if err := cache.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+
+		if got, _ := server.Get("test:{key}"); got != "value" {
+			t.Errorf("ожидалось value, получил %q", got)
+		}
+		if ttl := server.TTL("test:{key}"); ttl != time.Minute {
+			t.Errorf("ожидался TTL 1m, получил %v", ttl)
+		}
+
+		server.FastForward(time.Minute)
+		if _, err := cache.Get(ctx, "key"); !errors.Is(err, memcached.ErrCacheMiss) {
+			t.Errorf("ключ должен истечь, получил %v", err)
+		}
+	})
+
+	t.Run("Add и Delete", func(t *testing.T) {
+		if err := cache.Add(ctx, "add", []byte("1"), time.Minute); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if err := cache.Add(ctx, "add", []byte("2"), time.Minute); !errors.Is(err, memcached.ErrNotStored) {
+			t.Errorf("ожидалась ErrNotStored, получил %v", err)
+		}
+		if err := cache.Delete(ctx, "add"); err != nil {
+			t.Errorf("неожиданная ошибка: %v", err)
+		}
+		if err := cache.Delete(ctx, "add"); !errors.Is(err, memcached.ErrCacheMiss) {
+			t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+		}
+	})
+}
+
+func TestRedisCache_Counters(t *testing.T) {
+	ctx := context.Background()
+	cache, server := newTestRedis(t)
+
+	t.Run("Новый счётчик получает TTL по умолчанию", func(t *testing.T) {
+		if v, err := cache.Increment(ctx, "inc", 3); err != nil || v != 3 {
+			t.Fatalf("ожидалось 3, получил %d, %v", v, err)
+		}
+		if ttl := server.TTL("test:{inc}"); ttl != 30*time.Second {
+			t.Errorf("ожидался TTL 30s, получил %v", ttl)
+		}
+	})
+
+	t.Run("Инкремент сохраняет заданный TTL", func(t *testing.T) {
+		_ = cache.Set(ctx, "window", []byte("1"), 5*time.Second)
+		if v, err := cache.Increment(ctx, "window", 1); err != nil || v != 2 {
+			t.Fatalf("ожидалось 2, получил %d, %v", v, err)
+		}
+		if ttl := server.TTL("test:{window}"); ttl != 5*time.Second {
+			t.Errorf("ожидался TTL 5s, получил %v", ttl)
+		}
+	})
+
+	t.Run("Decrement не уходит ниже нуля", func(t *testing.T) {
+		_ = cache.Set(ctx, "floor", []byte("2"), 0)
+		if v, err := cache.Decrement(ctx, "floor", 5); err != nil || v != 0 {
+			t.Errorf("ожидалось 0, получил %d, %v", v, err)
+		}
+		if v, err := cache.Decrement(ctx, "missing", 1); err != nil || v != 0 {
+			t.Errorf("ожидалось 0, получил %d, %v", v, err)
+		}
+	})
+
+	t.Run("Нечисловое значение", func(t *testing.T) {
+		_ = cache.Set(ctx, "text", []byte("abc"), 0)
+		if _, err := cache.Increment(ctx, "text", 1); !errors.Is(err, memcached.ErrNotNumeric) {
+			t.Errorf("ожидалась ErrNotNumeric, получил %v", err)
+		}
+	})
+}
+
+func TestFactory_Cache(t *testing.T) {
+	ctx := context.Background()
+	factory := NewFactory(config.Config{})
+	t.Cleanup(func() { _ = factory.Close() })
+
+	first, err := factory.Cache(ctx, KindMemory)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	// Пустая настройка при выключенном memcached — тоже память процесса
+	second, err := factory.Cache(ctx, "")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if first != second {
+		t.Errorf("одинаковые настройки должны давать одно хранилище")
+	}
+
+	if _, err = factory.Cache(ctx, "etcd"); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("ожидалась ErrUnknownKind, получил %v", err)
+	}
+}
+
+func TestRedisCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	cache, server := newTestRedis(t)
+
+	_ = cache.Set(ctx, "key", []byte("v1"), time.Minute)
+	_, version, err := cache.GetWithVersion(ctx, "key")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	if err = cache.CompareAndSwap(ctx, "key", []byte("v2"), version, time.Minute); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if err = cache.CompareAndSwap(ctx, "key", []byte("v3"), version, time.Minute); !errors.Is(err, memcached.ErrCASConflict) {
+		t.Errorf("ожидалась ErrCASConflict, получил %v", err)
+	}
+	if got, _ := server.Get("test:{key}"); got != "v2" {
+		t.Errorf("ожидалось v2, получил %q", got)
+	}
+
+	// Версия живёт столько же, сколько значение
+	if ttl := server.TTL("test:{key}:version"); ttl != time.Minute {
+		t.Errorf("ожидался TTL версии 1m, получил %v", ttl)
+	}
+
+	_ = cache.Delete(ctx, "key")
+	if err = cache.CompareAndSwap(ctx, "key", []byte("v"), version, time.Minute); !errors.Is(err, memcached.ErrCacheMiss) {
+		t.Errorf("ожидалась ErrCacheMiss, получил %v", err)
+	}
+	if server.Exists("test:{key}:version") {
+		t.Errorf("версия должна удаляться вместе со значением")
+	}
+}
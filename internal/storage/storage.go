@@ -0,0 +1,140 @@
+// Package storage выбирает реализацию кэша по настройкам: memcached, память процесса или Redis
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+)
+
+// Поддерживаемые хранилища
+const (
+	KindMemcached = "memcached"
+	KindMemory    = "memory"
+	KindRedis     = "redis"
+)
+
+var ErrUnknownKind = errors.New("unknown storage kind")
+
+// Factory создаёт хранилища по имени и переиспользует уже созданные:
+// rate limiter и операции с одинаковой настройкой работают через одно подключение
+type Factory struct {
+	cfg    config.Config
+	mu     sync.Mutex
+	caches map[string]*memcached.NamespacedCache
+}
+
+func NewFactory(cfg config.Config) *Factory {
+	return &Factory{
+		cfg:    cfg,
+		caches: make(map[string]*memcached.NamespacedCache),
+	}
+}
+
+// Kind возвращает хранилище, которое будет выбрано для kind. Пустое значение —
+// прежнее поведение: memcached, если он включён, иначе память процесса.
+func (f *Factory) Kind(kind string) string {
+	if kind != "" {
+		return kind
+	}
+	if f.cfg.Memcached.Enable {
+		return KindMemcached
+	}
+	return KindMemory
+}
+
+// Cache возвращает хранилище kind, пустое значение выбирается через Kind
+func (f *Factory) Cache(ctx context.Context, kind string) (memcached.CacheInterface, error) {
+	kind = f.Kind(kind)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cache, ok := f.caches[kind]; ok {
+		return cache, nil
+	}
+
+	cache, err := f.create(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	// Поколения семейств нужны всем хранилищам: ни одно не удаляет ключи по префиксу
+	refresh := time.Duration(f.cfg.Namespaces.RefreshInterval) * time.Second
+	namespaced := memcached.NewNamespacedCache(cache, refresh)
+
+	f.caches[kind] = namespaced
+	return namespaced, nil
+}
+
+// Bump увеличивает поколение семейства во всех созданных хранилищах
+// и возвращает новое поколение в каждом из них
+func (f *Factory) Bump(ctx context.Context, family string) (map[string]uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	generations := make(map[string]uint64, len(f.caches))
+	for kind, cache := range f.caches {
+		n, err := cache.Bump(ctx, family)
+		if err != nil {
+			return generations, fmt.Errorf("error bumping %s in %s storage: %w", family, kind, err)
+		}
+		generations[kind] = n
+	}
+	return generations, nil
+}
+
+func (f *Factory) create(ctx context.Context, kind string) (memcached.CacheInterface, error) {
+	var (
+		cache memcached.CacheInterface
+		err   error
+	)
+	switch kind {
+	case KindMemcached:
+		// memcached.NewCache при выключенном memcached сам вернёт кэш в памяти
+		cache, err = memcached.NewCache(ctx, f.cfg)
+	case KindMemory:
+		// Память процесса не покидает сервис, кодек ей не нужен
+		return memcached.NewMemoryCache(ctx, f.cfg), nil
+	case KindRedis:
+		cache, err = NewRedisCache(ctx, f.cfg)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Общие хранилища получают сжатие и шифрование значений
+	codec, err := memcached.NewCodec(f.cfg.Codec)
+	if err != nil {
+		_ = cache.Close()
+		return nil, fmt.Errorf("error creating value codec: %w", err)
+	}
+	if !codec.Enabled() {
+		_ = codec.Close()
+		return cache, nil
+	}
+	return memcached.NewCodecCache(cache, codec), nil
+}
+
+// Close закрывает все созданные хранилища
+func (f *Factory) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	for kind, cache := range f.caches {
+		if err := cache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing %s storage: %w", kind, err))
+		}
+	}
+	clear(f.caches)
+
+	return errors.Join(errs...)
+}
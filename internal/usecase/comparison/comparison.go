@@ -0,0 +1,277 @@
+package comparison
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+var (
+	ErrNoDiagnostics     = errors.New("no diagnostics to compare")
+	ErrDifferentChildren = errors.New("diagnostics belong to different children")
+)
+
+// Direction — направление изменения показателя
+type Direction string
+
+const (
+	DirectionIncreased Direction = "increased"
+	DirectionDecreased Direction = "decreased"
+	DirectionUnchanged Direction = "unchanged"
+)
+
+// Kind — оценка изменения с учётом того, какое направление считается хорошим
+type Kind string
+
+const (
+	KindImprovement Kind = "improvement"
+	KindRegression  Kind = "regression"
+	KindStable      Kind = "stable"
+)
+
+// Point — значение показателя в одной диагностике
+type Point struct {
+	OperationID string    `json:"operation_id"`
+	Date        time.Time `json:"date"`
+	Value       float64   `json:"value"`
+}
+
+// Delta — изменение показателя между двумя диагностиками.
+// Percent равен nil, если исходное значение нулевое.
+type Delta struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Absolute  float64   `json:"absolute"`
+	Percent   *float64  `json:"percent,omitempty"`
+	Direction Direction `json:"direction"`
+	Kind      Kind      `json:"kind"`
+}
+
+// Indicator — динамика одного показателя по всем диагностикам
+type Indicator struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+	// Изменения между соседними диагностиками
+	Deltas []Delta `json:"deltas"`
+	// Изменение от первой диагностики к последней, nil если точка одна
+	Total *Delta `json:"total,omitempty"`
+}
+
+// Conclusion — текстовый вывод по показателю для отчётов
+type Conclusion struct {
+	Indicator string    `json:"indicator"`
+	Direction Direction `json:"direction"`
+	Kind      Kind      `json:"kind"`
+	Absolute  float64   `json:"absolute"`
+	Percent   *float64  `json:"percent,omitempty"`
+	Text      string    `json:"text"`
+}
+
+// Result — итог сравнения диагностик одного ребёнка
+type Result struct {
+	ChildID      string       `json:"child_id"`
+	Dates        []time.Time  `json:"dates"`
+	Indicators   []Indicator  `json:"indicators"`
+	Conclusions  []Conclusion `json:"conclusions"`
+	Improvements []string     `json:"improvements"`
+	Regressions  []string     `json:"regressions"`
+}
+
+// Comparator строит динамику показателей по нескольким диагностикам
+type Comparator struct {
+	lowerIsBetter   map[string]bool
+	stableThreshold float64
+}
+
+func NewComparator(cfg config.Config) *Comparator {
+	lowerIsBetter := make(map[string]bool, len(cfg.Comparison.LowerIsBetter))
+	for _, name := range cfg.Comparison.LowerIsBetter {
+		lowerIsBetter[normalizeName(name)] = true
+	}
+
+	return &Comparator{
+		lowerIsBetter:   lowerIsBetter,
+		stableThreshold: cfg.Comparison.StableThresholdPercent,
+	}
+}
+
+// Compare упорядочивает диагностики по дате и считает изменения каждого показателя
+func (c *Comparator) Compare(results []*file.DiagnosticResult) (*Result, error) {
+	start := time.Now()
+	defer func() {
+		metrics.UpdateComparisonTime(time.Since(start).Seconds())
+	}()
+
+	result, err := c.compare(results)
+	if err != nil {
+		metrics.UpdateComparisonError()
+		return nil, err
+	}
+
+	metrics.UpdateComparisonSuccess()
+	return result, nil
+}
+
+func (c *Comparator) compare(results []*file.DiagnosticResult) (*Result, error) {
+	if len(results) == 0 {
+		return nil, ErrNoDiagnostics
+	}
+
+	childID := results[0].ChildID
+	for _, r := range results[1:] {
+		if r.ChildID != childID {
+			return nil, ErrDifferentChildren
+		}
+	}
+
+	sorted := slices.Clone(results)
+	slices.SortStableFunc(sorted, func(a, b *file.DiagnosticResult) int {
+		if cmp := a.TestDate.Compare(b.TestDate); cmp != 0 {
+			return cmp
+		}
+		return strings.Compare(a.OperationID, b.OperationID)
+	})
+
+	result := &Result{
+		ChildID:      childID,
+		Dates:        make([]time.Time, 0, len(sorted)),
+		Indicators:   []Indicator{},
+		Conclusions:  []Conclusion{},
+		Improvements: []string{},
+		Regressions:  []string{},
+	}
+
+	// Показатели выводятся в порядке первого появления в бланках
+	byName := make(map[string]int)
+	for _, diagnostic := range sorted {
+		result.Dates = append(result.Dates, diagnostic.TestDate)
+
+		for _, ind := range diagnostic.Indicators {
+			key := normalizeName(ind.Name)
+			idx, ok := byName[key]
+			if !ok {
+				idx = len(result.Indicators)
+				byName[key] = idx
+				result.Indicators = append(result.Indicators, Indicator{Name: ind.Name})
+			}
+
+			result.Indicators[idx].Points = append(result.Indicators[idx].Points, Point{
+				OperationID: diagnostic.OperationID,
+				Date:        diagnostic.TestDate,
+				Value:       ind.Value,
+			})
+		}
+	}
+
+	for i := range result.Indicators {
+		ind := &result.Indicators[i]
+		lowerIsBetter := c.lowerIsBetter[normalizeName(ind.Name)]
+
+		ind.Deltas = make([]Delta, 0, len(ind.Points))
+		for j := 1; j < len(ind.Points); j++ {
+			ind.Deltas = append(ind.Deltas, c.delta(ind.Points[j-1], ind.Points[j], lowerIsBetter))
+		}
+
+		if len(ind.Points) < 2 {
+			continue
+		}
+
+		total := c.delta(ind.Points[0], ind.Points[len(ind.Points)-1], lowerIsBetter)
+		ind.Total = &total
+
+		result.Conclusions = append(result.Conclusions, Conclusion{
+			Indicator: ind.Name,
+			Direction: total.Direction,
+			Kind:      total.Kind,
+			Absolute:  total.Absolute,
+			Percent:   total.Percent,
+			Text:      conclusionText(ind.Name, ind.Points[0].Value, ind.Points[len(ind.Points)-1].Value, total),
+		})
+
+		switch total.Kind {
+		case KindImprovement:
+			result.Improvements = append(result.Improvements, ind.Name)
+		case KindRegression:
+			result.Regressions = append(result.Regressions, ind.Name)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Comparator) delta(from, to Point, lowerIsBetter bool) Delta {
+	d := Delta{
+		From:     from.Date,
+		To:       to.Date,
+		Absolute: Round(to.Value - from.Value),
+	}
+
+	if from.Value != 0 {
+		percent := Round((to.Value - from.Value) / math.Abs(from.Value) * 100)
+		d.Percent = &percent
+	}
+
+	switch {
+	case d.Absolute > 0:
+		d.Direction = DirectionIncreased
+	case d.Absolute < 0:
+		d.Direction = DirectionDecreased
+	default:
+		d.Direction = DirectionUnchanged
+	}
+
+	stable := d.Direction == DirectionUnchanged ||
+		(d.Percent != nil && math.Abs(*d.Percent) < c.stableThreshold)
+
+	switch {
+	case stable:
+		d.Kind = KindStable
+	case (d.Direction == DirectionIncreased) != lowerIsBetter:
+		d.Kind = KindImprovement
+	default:
+		d.Kind = KindRegression
+	}
+
+	return d
+}
+
+// conclusionText формирует фразу вида «Показатель «Речь» увеличился на 12,5%»
+func conclusionText(name string, from, to float64, d Delta) string {
+	switch d.Direction {
+	case DirectionUnchanged:
+		return fmt.Sprintf("Показатель «%s» не изменился (%s)", name, formatNumber(to))
+	case DirectionIncreased:
+		if d.Percent == nil {
+			return fmt.Sprintf("Показатель «%s» увеличился с %s до %s", name, formatNumber(from), formatNumber(to))
+		}
+		return fmt.Sprintf("Показатель «%s» увеличился на %s%%", name, formatNumber(*d.Percent))
+	default:
+		if d.Percent == nil {
+			return fmt.Sprintf("Показатель «%s» уменьшился с %s до %s", name, formatNumber(from), formatNumber(to))
+		}
+		return fmt.Sprintf("Показатель «%s» уменьшился на %s%%", name, formatNumber(-*d.Percent))
+	}
+}
+
+// formatNumber печатает число с одним знаком после запятой, без лишнего «,0»
+func formatNumber(v float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(Round(v), 'f', -1, 64), ".", ",")
+}
+
+// Round округляет до одного знака после запятой — с такой точностью
+// показатели выводятся в отчётах и выгрузках
+func Round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,90 @@
+package comparison
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+func diagnostic(id, child string, date time.Time, indicators ...file.Indicator) *file.DiagnosticResult {
+	return &file.DiagnosticResult{
+		OperationID: id,
+		ChildID:     child,
+		TestDate:    date,
+		Indicators:  indicators,
+	}
+}
+
+func TestComparator_Compare(t *testing.T) {
+	comparator := NewComparator(config.Config{
+		Comparison: config.Comparison{
+			LowerIsBetter:          []string{"Ошибки"},
+			StableThresholdPercent: 1,
+		},
+	})
+
+	march := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	// Диагностики переданы не по порядку дат
+	result, err := comparator.Compare([]*file.DiagnosticResult{
+		diagnostic("op2", "A-17", may,
+			file.Indicator{Name: "Речь", Value: 12},
+			file.Indicator{Name: "Ошибки", Value: 6},
+			file.Indicator{Name: "Внимание", Value: 50.2},
+		),
+		diagnostic("op1", "A-17", march,
+			file.Indicator{Name: "Речь", Value: 10},
+			file.Indicator{Name: "Ошибки", Value: 4},
+			file.Indicator{Name: "Внимание", Value: 50},
+		),
+	})
+	if err != nil {
+		t.Fatalf("ожидался nil, получил %v", err)
+	}
+
+	if !slices.Equal(result.Dates, []time.Time{march, may}) {
+		t.Errorf("даты должны быть упорядочены, получил %v", result.Dates)
+	}
+
+	speech := result.Indicators[0]
+	if speech.Total == nil || speech.Total.Absolute != 2 || *speech.Total.Percent != 20 {
+		t.Fatalf("неверное изменение речи: %+v", speech.Total)
+	}
+
+	wantText := "Показатель «Речь» увеличился на 20%"
+	if result.Conclusions[0].Text != wantText {
+		t.Errorf("ожидался вывод %q, получил %q", wantText, result.Conclusions[0].Text)
+	}
+
+	if !slices.Equal(result.Improvements, []string{"Речь"}) {
+		t.Errorf("ожидалось улучшение речи, получил %v", result.Improvements)
+	}
+	if !slices.Equal(result.Regressions, []string{"Ошибки"}) {
+		t.Errorf("рост ошибок должен быть регрессом, получил %v", result.Regressions)
+	}
+	if result.Indicators[2].Total.Kind != KindStable {
+		t.Errorf("изменение меньше порога должно быть стабильным, получил %v", result.Indicators[2].Total.Kind)
+	}
+}
+
+func TestComparator_Errors(t *testing.T) {
+	comparator := NewComparator(config.Config{})
+	date := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := comparator.Compare(nil); !errors.Is(err, ErrNoDiagnostics) {
+		t.Errorf("ожидалась ErrNoDiagnostics, получил %v", err)
+	}
+
+	_, err := comparator.Compare([]*file.DiagnosticResult{
+		diagnostic("op1", "A-17", date),
+		diagnostic("op2", "B-3", date),
+	})
+	if !errors.Is(err, ErrDifferentChildren) {
+		t.Errorf("ожидалась ErrDifferentChildren, получил %v", err)
+	}
+}
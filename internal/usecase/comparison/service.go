@@ -0,0 +1,62 @@
+package comparison
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+)
+
+// Service собирает результаты извлечения по операциям и сравнивает их
+type Service struct {
+	operations *operation.Store
+	extractor  *file.Extractor
+	comparator *Comparator
+}
+
+func NewService(operations *operation.Store, extractor *file.Extractor, comparator *Comparator) *Service {
+	return &Service{
+		operations: operations,
+		extractor:  extractor,
+		comparator: comparator,
+	}
+}
+
+// ForOperation сравнивает диагностику операции id с диагностиками того же ребёнка,
+// загруженными в том же запросе
+func (s *Service) ForOperation(ctx context.Context, id string) (*Result, error) {
+	own, err := s.extractor.Result(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting extraction result: %w", err)
+	}
+
+	batch, err := s.operations.Batch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*file.DiagnosticResult{own}
+	for _, sibling := range batch {
+		if sibling == id {
+			continue
+		}
+
+		// Операции без результата (ещё в обработке или с ошибкой) в сравнение не попадают
+		result, err := s.extractor.Result(ctx, sibling)
+		if errors.Is(err, memcached.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting extraction result: %w", err)
+		}
+
+		if result.ChildID == own.ChildID {
+			results = append(results, result)
+		}
+	}
+
+	return s.comparator.Compare(results)
+}
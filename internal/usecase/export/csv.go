@@ -0,0 +1,149 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+)
+
+// Метка порядка байтов, по которой Excel распознаёт UTF-8 и корректно показывает кириллицу
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Разделитель по умолчанию — его ожидает Excel с русской локалью
+const defaultCSVDelimiter = ';'
+
+const dateLayout = "02.01.2006"
+
+// Метка формата в метриках экспорта
+const csvFormat = "csv"
+
+var csvHeader = []string{
+	"Показатель",
+	"Дата",
+	"Значение",
+	"Изменение",
+	"Изменение, %",
+	"Изменение от первой диагностики",
+	"Изменение от первой диагностики, %",
+}
+
+// CSVExporter выгружает результат сравнения в CSV: строка на показатель в каждой диагностике
+type CSVExporter struct {
+	delimiter rune
+}
+
+func NewCSVExporter(cfg config.Config) (*CSVExporter, error) {
+	delimiter := rune(defaultCSVDelimiter)
+	if cfg.Export.CSVDelimiter != "" {
+		r, size := utf8.DecodeRuneInString(cfg.Export.CSVDelimiter)
+		if size != len(cfg.Export.CSVDelimiter) || !validDelimiter(r) {
+			return nil, fmt.Errorf("invalid csv delimiter %q", cfg.Export.CSVDelimiter)
+		}
+		delimiter = r
+	}
+
+	return &CSVExporter{delimiter: delimiter}, nil
+}
+
+// validDelimiter повторяет проверку csv.Writer: с таким разделителем
+// запись каждой строки завершилась бы ошибкой
+func validDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// ContentType — MIME-тип выгрузки
+func (e *CSVExporter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// Export пишет CSV в w
+func (e *CSVExporter) Export(w io.Writer, result *comparison.Result) error {
+	start := time.Now()
+	defer func() {
+		metrics.UpdateExportTime(csvFormat, time.Since(start).Seconds())
+	}()
+
+	if err := e.write(w, result); err != nil {
+		metrics.UpdateExportError(csvFormat)
+		return fmt.Errorf("error exporting csv: %w", err)
+	}
+
+	metrics.UpdateExportSuccess(csvFormat)
+	return nil
+}
+
+func (e *CSVExporter) write(w io.Writer, result *comparison.Result) error {
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = e.delimiter
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, ind := range result.Indicators {
+		for i, point := range ind.Points {
+			record := []string{
+				ind.Name,
+				point.Date.Format(dateLayout),
+				e.number(point.Value),
+				"", "", "", "",
+			}
+
+			// Для первой диагностики изменений нет
+			if i > 0 {
+				prev := ind.Deltas[i-1]
+				record[3] = e.number(prev.Absolute)
+				record[4] = e.percent(prev.Percent)
+
+				first := ind.Points[0]
+				record[5] = e.number(point.Value - first.Value)
+				record[6] = e.percentBetween(first.Value, point.Value)
+			}
+
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// number форматирует число; при разделителе, отличном от запятой, дробная часть
+// отделяется запятой, как принято в русской локали
+func (e *CSVExporter) number(v float64) string {
+	s := strconv.FormatFloat(comparison.Round(v), 'f', -1, 64)
+	if e.delimiter != ',' {
+		s = strings.ReplaceAll(s, ".", ",")
+	}
+	return s
+}
+
+func (e *CSVExporter) percent(p *float64) string {
+	if p == nil {
+		return ""
+	}
+	return e.number(*p)
+}
+
+func (e *CSVExporter) percentBetween(from, to float64) string {
+	if from == 0 {
+		return ""
+	}
+	p := (to - from) / math.Abs(from) * 100
+	return e.percent(&p)
+}
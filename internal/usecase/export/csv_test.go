@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+)
+
+func TestCSVExporter_Export(t *testing.T) {
+	march := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	result, err := comparison.NewComparator(config.Config{}).Compare([]*file.DiagnosticResult{
+		{OperationID: "op1", ChildID: "A-17", TestDate: march, Indicators: []file.Indicator{{Name: "Речь", Value: 10}}},
+		{OperationID: "op2", ChildID: "A-17", TestDate: may, Indicators: []file.Indicator{{Name: "Речь", Value: 12.5}}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка сравнения: %v", err)
+	}
+
+	var buf bytes.Buffer
+	exporter, err := NewCSVExporter(config.Config{})
+	if err != nil {
+		t.Fatalf("ошибка создания экспортёра: %v", err)
+	}
+	if err = exporter.Export(&buf, result); err != nil {
+		t.Fatalf("ошибка экспорта: %v", err)
+	}
+
+	if !bytes.HasPrefix(buf.Bytes(), utf8BOM) {
+		t.Fatalf("файл должен начинаться с BOM")
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf.Bytes()[len(utf8BOM):])), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("ожидалось 3 строки, получил %d: %q", len(lines), lines)
+	}
+
+	want := "Речь;01.05.2025;12,5;2,5;25;2,5;25"
+	if lines[2] != want {
+		t.Errorf("ожидалась строка %q, получил %q", want, lines[2])
+	}
+}
+
+func TestCSVExporter_Delimiter(t *testing.T) {
+	exporter, err := NewCSVExporter(config.Config{Export: config.Export{CSVDelimiter: ","}})
+	if err != nil {
+		t.Fatalf("ошибка создания экспортёра: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = exporter.Export(&buf, &comparison.Result{}); err != nil {
+		t.Fatalf("ошибка экспорта: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Показатель,Дата") {
+		t.Errorf("ожидался разделитель-запятая, получил %q", buf.String())
+	}
+}
+
+func TestNewCSVExporter_InvalidDelimiter(t *testing.T) {
+	for _, delimiter := range []string{"\"", "\r", "\n", "\uFFFD", "\xff", ";;"} {
+		if _, err := NewCSVExporter(config.Config{Export: config.Export{CSVDelimiter: delimiter}}); err == nil {
+			t.Errorf("разделитель %q должен был быть отклонён", delimiter)
+		}
+	}
+}
@@ -2,32 +2,26 @@ package file
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Caritas-Team/reviewer/internal/logger"
-	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
 )
 
 type Cleaner struct {
-	cache    *memcached.Cache
-	filesDir string
-	log      *logger.Logger
+	operations *operation.Store
+	filesDir   string
+	log        *logger.Logger
 }
 
-type fileMetadata struct {
-	UUID     string `json:"uuid"`
-	Status   string `json:"status"`
-	Filename string `json:"filename"`
-}
-
-func NewFileCleaner(log *logger.Logger, cache *memcached.Cache) *Cleaner {
+func NewFileCleaner(log *logger.Logger, operations *operation.Store) *Cleaner {
 	return &Cleaner{
-		cache:    cache,
-		filesDir: "./files",
-		log:      log,
+		operations: operations,
+		filesDir:   filesDir,
+		log:        log,
 	}
 }
 
@@ -39,49 +33,53 @@ func (fc *Cleaner) DeleteDownloadedFiles(ctx context.Context) error {
 
 	fc.log.Info("Found files in directory", "count", len(files), "files", files)
 
+	// У операции может быть несколько файлов: загрузка <uuid>.pdf и отчёт <uuid>.report.pdf
+	filesByUUID := make(map[string][]string)
+	var uuids []string
 	for _, file := range files {
 		if file.IsDir() {
 			continue
 		}
 
 		filename := file.Name()
-		uuid := filename[:len(filename)-len(filepath.Ext(filename))]
+		uuid, _, _ := strings.Cut(filename, ".")
+		if _, ok := filesByUUID[uuid]; !ok {
+			uuids = append(uuids, uuid)
+		}
+		filesByUUID[uuid] = append(filesByUUID[uuid], filename)
+	}
 
-		status, err := fc.getFileStatus(ctx, uuid)
+	for _, uuid := range uuids {
+		op, err := fc.operations.Get(ctx, uuid)
 		if err != nil {
 			fc.log.Warn("Cannot get file status", "uuid", uuid, "err", err)
 			continue
 		}
 
-		if status == "DOWNLOADED" {
+		// DOWNLOADED — конечный статус, после проверки операцию уже никто не изменит
+		if op.Status != operation.StatusDownloaded {
+			continue
+		}
+
+		removed := true
+		for _, filename := range filesByUUID[uuid] {
 			filePath := filepath.Join(fc.filesDir, filename)
 			if err = os.Remove(filePath); err != nil {
 				fc.log.Error("Cannot remove downloaded file", "uuid", uuid, "path", filePath, "err", err)
-				continue
-			}
-
-			if err = fc.cache.Delete(ctx, uuid); err != nil {
-				fc.log.Error("Error removing data", "uuid", uuid, "error", err)
-				continue
+				removed = false
 			}
-
-			fc.log.Info("Removed file", "uuid", uuid)
 		}
-	}
-
-	return nil
-}
+		if !removed {
+			continue
+		}
 
-func (fc *Cleaner) getFileStatus(ctx context.Context, uuid string) (string, error) {
-	data, err := fc.cache.Get(ctx, uuid)
-	if err != nil {
-		return "", fmt.Errorf("error getting file from cache: %w", err)
-	}
+		if err = fc.operations.Delete(ctx, uuid); err != nil {
+			fc.log.Error("Error removing data", "uuid", uuid, "error", err)
+			continue
+		}
 
-	var metadata fileMetadata
-	if err = json.Unmarshal(data, &metadata); err != nil {
-		return "", fmt.Errorf("error unmarshalling file metadata: %w", err)
+		fc.log.Info("Removed file", "uuid", uuid)
 	}
 
-	return metadata.Status, nil
+	return nil
 }
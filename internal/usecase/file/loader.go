@@ -1 +1,265 @@
 package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/ledongthuc/pdf"
+)
+
+// Причины ошибок извлечения данных
+const (
+	ReasonUnreadablePDF   = "unreadable_pdf"
+	ReasonNoText          = "no_text"
+	ReasonMissingChildID  = "missing_child_id"
+	ReasonMissingTestDate = "missing_test_date"
+	ReasonNoIndicators    = "no_indicators"
+)
+
+// ExtractionError — ошибка извлечения данных с машиночитаемой причиной
+type ExtractionError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ExtractionError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("data extraction failed (%s): %v", e.Reason, e.Err)
+	}
+	return fmt.Sprintf("data extraction failed (%s)", e.Reason)
+}
+
+func (e *ExtractionError) Unwrap() error {
+	return e.Err
+}
+
+// Indicator — показатель диагностики с числовым значением
+type Indicator struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// DiagnosticResult — данные, извлечённые из одного PDF с диагностикой
+type DiagnosticResult struct {
+	OperationID string      `json:"operation_id"`
+	ChildID     string      `json:"child_id"`
+	TestDate    time.Time   `json:"test_date"`
+	Indicators  []Indicator `json:"indicators"`
+}
+
+var (
+	childIDPattern   = regexp.MustCompile(`(?i)^(?:id|код|номер)?\s*(?:ребенка|ребёнка|ребенок|ребёнок|child(?:\s+id)?)\s*[:№#]\s*(.+)$`)
+	testDatePattern  = regexp.MustCompile(`(?i)^(?:дата(?:\s+(?:тестирования|диагностики|обследования))?|test\s+date|date)\s*:\s*(\d{2}\.\d{2}\.\d{4}|\d{4}-\d{2}-\d{2})`)
+	indicatorPattern = regexp.MustCompile(`^(\p{L}[^:=]*?)\s*(?:[:=]\s*|\s+)(-?\d+(?:[.,]\d+)?)\s*%?$`)
+)
+
+// Форматы дат, которые встречаются в бланках диагностики
+var testDateLayouts = []string{"02.01.2006", "2006-01-02"}
+
+// Extractor извлекает данные диагностики из загруженных PDF
+type Extractor struct {
+	storage *Storage
+	cache   memcached.CacheInterface
+	ttl     time.Duration
+	log     *logger.Logger
+}
+
+func NewExtractor(log *logger.Logger, storage *Storage, cache memcached.CacheInterface, cfg config.Config) *Extractor {
+	return &Extractor{
+		storage: storage,
+		cache:   cache,
+		ttl:     time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		log:     log,
+	}
+}
+
+// Process извлекает данные из файла операции и сохраняет результат в кэш.
+// Реализует Processor, поэтому используется как обработчик в Scheduler.
+func (e *Extractor) Process(ctx context.Context, id string) error {
+	start := time.Now()
+
+	result, err := e.extract(ctx, id)
+	metrics.UpdateDataExtractionTime(time.Since(start).Seconds())
+	if err != nil {
+		metrics.UpdateDataExtractionError()
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		metrics.UpdateDataExtractionError()
+		return fmt.Errorf("error marshalling extraction result: %w", err)
+	}
+
+	// Результат с большим числом показателей может превысить лимит memcached на ключ
+	if err = memcached.SetLarge(ctx, e.cache, resultKey(id), data, e.ttl); err != nil {
+		metrics.UpdateDataExtractionError()
+		return fmt.Errorf("error saving extraction result: %w", err)
+	}
+
+	metrics.UpdateDataExtractionSuccess()
+	e.log.Info("data extracted", "id", id, "child_id", result.ChildID, "indicators", len(result.Indicators))
+	return nil
+}
+
+// Result возвращает сохранённый результат извлечения
+func (e *Extractor) Result(ctx context.Context, id string) (*DiagnosticResult, error) {
+	data, err := memcached.GetLarge(ctx, e.cache, resultKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var result DiagnosticResult
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error unmarshalling extraction result: %w", err)
+	}
+	return &result, nil
+}
+
+func (e *Extractor) extract(ctx context.Context, id string) (*DiagnosticResult, error) {
+	if err := e.storage.Verify(ctx, id); err != nil {
+		if errors.Is(err, ErrNotPDF) {
+			return nil, &ExtractionError{Reason: ReasonUnreadablePDF, Err: err}
+		}
+		return nil, err
+	}
+
+	lines, err := e.readLines(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ParseDiagnostic(lines)
+	if err != nil {
+		return nil, err
+	}
+	result.OperationID = id
+
+	return result, nil
+}
+
+// readLines достаёт текст PDF построчно, сохраняя порядок строк на странице
+func (e *Extractor) readLines(ctx context.Context, id string) (lines []string, err error) {
+	f, err := e.storage.Open(id)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file info: %w", err)
+	}
+
+	// Парсер паникует на повреждённых файлах
+	defer func() {
+		if r := recover(); r != nil {
+			lines = nil
+			err = &ExtractionError{Reason: ReasonUnreadablePDF, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	reader, err := pdf.NewReader(f, info.Size())
+	if err != nil {
+		return nil, &ExtractionError{Reason: ReasonUnreadablePDF, Err: err}
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rows, err := reader.Page(i).GetTextByRow()
+		if err != nil {
+			return nil, &ExtractionError{Reason: ReasonUnreadablePDF, Err: err}
+		}
+
+		for _, row := range rows {
+			parts := make([]string, 0, len(row.Content))
+			for _, text := range row.Content {
+				parts = append(parts, text.S)
+			}
+			lines = append(lines, strings.Join(parts, " "))
+		}
+	}
+
+	return lines, nil
+}
+
+// ParseDiagnostic разбирает строки бланка диагностики: идентификатор ребёнка,
+// дату тестирования и показатели вида «Название: 12,5»
+func ParseDiagnostic(lines []string) (*DiagnosticResult, error) {
+	result := &DiagnosticResult{}
+	hasText := false
+
+	for _, raw := range lines {
+		line := strings.Join(strings.Fields(raw), " ")
+		if line == "" {
+			continue
+		}
+		hasText = true
+
+		if m := childIDPattern.FindStringSubmatch(line); m != nil {
+			if result.ChildID == "" {
+				result.ChildID = strings.TrimSpace(m[1])
+			}
+			continue
+		}
+
+		if m := testDatePattern.FindStringSubmatch(line); m != nil {
+			if result.TestDate.IsZero() {
+				result.TestDate = parseTestDate(m[1])
+			}
+			continue
+		}
+
+		if m := indicatorPattern.FindStringSubmatch(line); m != nil {
+			value, err := strconv.ParseFloat(strings.ReplaceAll(m[2], ",", "."), 64)
+			if err != nil {
+				continue
+			}
+			result.Indicators = append(result.Indicators, Indicator{
+				Name:  strings.TrimSpace(m[1]),
+				Value: value,
+			})
+		}
+	}
+
+	switch {
+	case !hasText:
+		return nil, &ExtractionError{Reason: ReasonNoText}
+	case result.ChildID == "":
+		return nil, &ExtractionError{Reason: ReasonMissingChildID}
+	case result.TestDate.IsZero():
+		return nil, &ExtractionError{Reason: ReasonMissingTestDate}
+	case len(result.Indicators) == 0:
+		return nil, &ExtractionError{Reason: ReasonNoIndicators}
+	}
+
+	return result, nil
+}
+
+func parseTestDate(value string) time.Time {
+	for _, layout := range testDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func resultKey(id string) string {
+	return "extraction:" + id
+}
@@ -0,0 +1,73 @@
+package file
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDiagnostic(t *testing.T) {
+	lines := []string{
+		"Результаты диагностики",
+		"ID ребёнка: A-17",
+		"Дата тестирования: 14.03.2025",
+		"Моторика: 12,5",
+		"Речь   8",
+		"Внимание = 40 %",
+	}
+
+	result, err := ParseDiagnostic(lines)
+	if err != nil {
+		t.Fatalf("ожидался nil, получил %v", err)
+	}
+
+	if result.ChildID != "A-17" {
+		t.Errorf("ожидался ребёнок A-17, получил %q", result.ChildID)
+	}
+
+	wantDate := time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)
+	if !result.TestDate.Equal(wantDate) {
+		t.Errorf("ожидалась дата %v, получил %v", wantDate, result.TestDate)
+	}
+
+	want := []Indicator{
+		{Name: "Моторика", Value: 12.5},
+		{Name: "Речь", Value: 8},
+		{Name: "Внимание", Value: 40},
+	}
+	if len(result.Indicators) != len(want) {
+		t.Fatalf("ожидалось %d показателей, получил %v", len(want), result.Indicators)
+	}
+	for i := range want {
+		if result.Indicators[i] != want[i] {
+			t.Errorf("показатель %d: ожидался %v, получил %v", i, want[i], result.Indicators[i])
+		}
+	}
+}
+
+func TestParseDiagnostic_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		reason string
+	}{
+		{"пустой документ", []string{" ", ""}, ReasonNoText},
+		{"нет ребёнка", []string{"Дата: 2025-03-14", "Речь: 8"}, ReasonMissingChildID},
+		{"нет даты", []string{"Ребёнок: A-17", "Речь: 8"}, ReasonMissingTestDate},
+		{"нет показателей", []string{"Ребёнок: A-17", "Дата: 2025-03-14"}, ReasonNoIndicators},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDiagnostic(tt.lines)
+
+			var extractionErr *ExtractionError
+			if !errors.As(err, &extractionErr) {
+				t.Fatalf("ожидалась ExtractionError, получил %v", err)
+			}
+			if extractionErr.Reason != tt.reason {
+				t.Errorf("ожидалась причина %s, получил %s", tt.reason, extractionErr.Reason)
+			}
+		})
+	}
+}
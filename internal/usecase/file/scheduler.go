@@ -1 +1,272 @@
 package file
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+)
+
+var (
+	ErrQueueFull        = errors.New("processing queue is full")
+	ErrSchedulerStopped = errors.New("scheduler is stopped")
+	// ErrDeferred возвращается обработчиком, когда операция ждёт остальные файлы запроса.
+	// Статус PROGRESS сохраняется, итоговый выставит тот, кто завершит обработку.
+	ErrDeferred = errors.New("processing deferred")
+)
+
+// Значения по умолчанию, если в конфиге ничего не задано
+const (
+	defaultWorkers           = 4
+	defaultQueueSize         = 100
+	defaultMaxProcessingTime = 60 * time.Second
+	// Как часто воркер проверяет, не освободился ли общий слот обработки
+	maxSlotWait = time.Second
+)
+
+// Processor выполняет обработку одной операции
+type Processor interface {
+	Process(ctx context.Context, id string) error
+}
+
+// Finalizer — Processor, которому нужно знать об операциях, завершившихся
+// ошибкой вне Process: при остановке, без слота обработки или без постановки
+// в очередь. Соседи по запросу, отложенные через ErrDeferred, ждут каждую
+// операцию запроса, и без этого остались бы в PROGRESS до истечения TTL.
+type Finalizer interface {
+	Finalize(ctx context.Context, id string) error
+}
+
+// ProcessorFunc позволяет использовать обычную функцию как Processor
+type ProcessorFunc func(ctx context.Context, id string) error
+
+func (f ProcessorFunc) Process(ctx context.Context, id string) error {
+	return f(ctx, id)
+}
+
+type job struct {
+	id         string
+	enqueuedAt time.Time
+}
+
+// Scheduler обрабатывает операции пулом воркеров: NEW → PROGRESS → DONE/ERROR
+type Scheduler struct {
+	operations *operation.Store
+	processor  Processor
+	slots      *user.ConcurrencyLimiter
+	slotWait   time.Duration
+	log        *logger.Logger
+	queue      chan job
+	workers    int
+	timeout    time.Duration
+	inProgress atomic.Int64
+	stopped    atomic.Bool
+	wg         sync.WaitGroup
+}
+
+func NewScheduler(log *logger.Logger, operations *operation.Store, processor Processor, slots *user.ConcurrencyLimiter, cfg config.Config) *Scheduler {
+	workers := cfg.Files.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	queueSize := cfg.Files.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	timeout := time.Duration(cfg.Files.MaxProcessingTime) * time.Second
+	if timeout <= 0 {
+		timeout = defaultMaxProcessingTime
+	}
+
+	return &Scheduler{
+		operations: operations,
+		processor:  processor,
+		slots:      slots,
+		slotWait:   maxSlotWait,
+		log:        log,
+		queue:      make(chan job, queueSize),
+		workers:    workers,
+		timeout:    timeout,
+	}
+}
+
+// Start запускает воркеры, они работают до отмены ctx
+func (s *Scheduler) Start(ctx context.Context) {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker(ctx)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.stopped.Store(true)
+	}()
+
+	s.log.Info("scheduler started", "workers", s.workers, "queue_size", cap(s.queue))
+}
+
+// Wait дожидается завершения воркеров и переводит необработанные операции в ERROR
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+
+	for {
+		select {
+		case j := <-s.queue:
+			s.finish(context.Background(), j.id, operation.StatusError, ErrSchedulerStopped.Error())
+		default:
+			metrics.UpdateQueueLength(0)
+			return
+		}
+	}
+}
+
+// Enqueue ставит операцию в очередь, не блокируясь при переполнении
+func (s *Scheduler) Enqueue(id string) error {
+	if s.stopped.Load() {
+		return ErrSchedulerStopped
+	}
+
+	select {
+	case s.queue <- job{id: id, enqueuedAt: time.Now()}:
+		metrics.UpdateQueueLength(float64(len(s.queue)))
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (s *Scheduler) worker(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			s.handle(ctx, j)
+		}
+	}
+}
+
+func (s *Scheduler) handle(ctx context.Context, j job) {
+	metrics.UpdateQueueLength(float64(len(s.queue)))
+	metrics.UpdateWorkerQueueDelay(time.Since(j.enqueuedAt).Seconds())
+
+	slot, err := s.acquireSlot(ctx)
+	if err != nil {
+		s.finish(context.WithoutCancel(ctx), j.id, operation.StatusError, ErrSchedulerStopped.Error())
+		return
+	}
+	defer slot.Release(context.WithoutCancel(ctx))
+
+	if _, err := s.operations.Transition(ctx, j.id, operation.StatusProgress, ""); err != nil {
+		s.log.Error("cannot set operation status", "id", j.id, "status", operation.StatusProgress, "err", err)
+		// Операция, которая уже завершена, не меняется. Иначе она осталась бы
+		// NEW навсегда, и соседи по запросу ждали бы её.
+		if !errors.Is(err, operation.ErrInvalidTransition) {
+			s.finish(context.WithoutCancel(ctx), j.id, operation.StatusError, "cannot start processing")
+		}
+		return
+	}
+
+	metrics.UpdateCurrentFilesInProgress(float64(s.inProgress.Add(1)))
+	defer func() {
+		metrics.UpdateCurrentFilesInProgress(float64(s.inProgress.Add(-1)))
+	}()
+
+	jobCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err = s.processor.Process(jobCtx, j.id)
+	duration := time.Since(start).Seconds()
+
+	// Итоговый статус записываем даже при остановке сервиса
+	statusCtx := context.WithoutCancel(ctx)
+
+	if errors.Is(err, ErrDeferred) {
+		s.log.Debug("operation deferred", "id", j.id)
+		metrics.UpdateFileProcessingTime("deferred", duration)
+		return
+	}
+
+	if err != nil {
+		message := err.Error()
+		if errors.Is(jobCtx.Err(), context.DeadlineExceeded) {
+			message = "processing timeout exceeded"
+		}
+
+		s.log.Warn("operation failed", "id", j.id, "err", err)
+		metrics.UpdateFileProcessingTime(strings.ToLower(string(operation.StatusError)), duration)
+		s.finish(statusCtx, j.id, operation.StatusError, message)
+		return
+	}
+
+	metrics.UpdateFileProcessingTime(strings.ToLower(string(operation.StatusDone)), duration)
+	s.finish(statusCtx, j.id, operation.StatusDone, "")
+}
+
+// acquireSlot ждёт общий слот обработки: без него экземпляры вместе
+// обрабатывали бы больше операций, чем задано в concurrency.processing
+func (s *Scheduler) acquireSlot(ctx context.Context) (*user.Slot, error) {
+	if s.slots == nil {
+		return nil, nil
+	}
+
+	for {
+		slot, err := s.slots.AcquireProcessing(ctx)
+		if err == nil {
+			// Пока ждали хранилище, сервис могли остановить
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				slot.Release(context.WithoutCancel(ctx))
+				return nil, ctxErr
+			}
+			return slot, nil
+		}
+
+		wait := s.slotWait
+		var full *memcached.SemaphoreFullError
+		if errors.As(err, &full) && full.RetryAfter > 0 {
+			wait = min(wait, full.RetryAfter)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Fail переводит в ERROR операцию, которую не удалось поставить в очередь
+func (s *Scheduler) Fail(ctx context.Context, id string, errMessage string) {
+	s.finish(ctx, id, operation.StatusError, errMessage)
+}
+
+func (s *Scheduler) finish(ctx context.Context, id string, status operation.Status, errMessage string) {
+	if _, err := s.operations.Transition(ctx, id, status, errMessage); err != nil {
+		s.log.Error("cannot set operation status", "id", id, "status", status, "err", err)
+	}
+
+	// Успешную операцию Processor завершает сам, а после ошибки соседи по
+	// запросу могли остаться без операции, которую ждали
+	if status != operation.StatusError {
+		return
+	}
+	if finalizer, ok := s.processor.(Finalizer); ok {
+		if err := finalizer.Finalize(ctx, id); err != nil {
+			s.log.Error("cannot finalize batch", "id", id, "err", err)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/Caritas-Team/reviewer/internal/usecase/user"
+	"github.com/google/uuid"
+)
+
+func newTestCache(t *testing.T) *memcached.MemoryCache {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return memcached.NewMemoryCache(ctx, config.Config{})
+}
+
+func newTestScheduler(cache memcached.CacheInterface, processor Processor, slots *user.ConcurrencyLimiter, workers, queueSize int) (*Scheduler, *operation.Store) {
+	cfg := config.Config{
+		Logging:   config.Logging{Level: "error", Format: "text"},
+		Memcached: config.Memcached{DefaultTTL: 60},
+		Files:     config.Files{Workers: workers, QueueSize: queueSize, MaxProcessingTime: 5},
+	}
+	operations := operation.NewStore(cache, cfg)
+	scheduler := NewScheduler(logger.NewLogger(cfg), operations, processor, slots, cfg)
+	scheduler.slotWait = 5 * time.Millisecond
+	return scheduler, operations
+}
+
+func createTestOperation(t *testing.T, operations *operation.Store) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	if err := operations.Create(context.Background(), &operation.Operation{ID: id, Filename: "test.pdf"}); err != nil {
+		t.Fatalf("ошибка создания операции: %v", err)
+	}
+	return id
+}
+
+// waitStatus ждёт, пока операция перейдёт в статус want
+func waitStatus(t *testing.T, operations *operation.Store, id string, want operation.Status) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		op, err := operations.Get(context.Background(), id)
+		if err == nil && op.Status == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("операция %s не перешла в статус %s: %+v, %v", id, want, op, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduler_Process(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want operation.Status
+	}{
+		{"успешная обработка", nil, operation.StatusDone},
+		{"ошибка обработки", errors.New("broken pdf"), operation.StatusError},
+		// Итоговый статус выставит тот, кто завершит обработку запроса
+		{"отложенная обработка", ErrDeferred, operation.StatusProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var processed atomic.Int64
+			scheduler, operations := newTestScheduler(newTestCache(t), ProcessorFunc(func(ctx context.Context, id string) error {
+				processed.Add(1)
+				return tt.err
+			}), nil, 2, 10)
+			scheduler.Start(ctx)
+
+			id := createTestOperation(t, operations)
+			if err := scheduler.Enqueue(id); err != nil {
+				t.Fatalf("ошибка постановки в очередь: %v", err)
+			}
+
+			waitStatus(t, operations, id, tt.want)
+			cancel()
+			scheduler.Wait()
+
+			if processed.Load() != 1 {
+				t.Errorf("операция должна обрабатываться один раз, обработана %d", processed.Load())
+			}
+		})
+	}
+}
+
+func TestScheduler_QueueFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	scheduler, operations := newTestScheduler(newTestCache(t), ProcessorFunc(func(ctx context.Context, id string) error {
+		<-release
+		return nil
+	}), nil, 1, 1)
+	scheduler.Start(ctx)
+
+	// Первую операцию забирает единственный воркер, вторая занимает очередь
+	first := createTestOperation(t, operations)
+	if err := scheduler.Enqueue(first); err != nil {
+		t.Fatalf("ошибка постановки в очередь: %v", err)
+	}
+	waitStatus(t, operations, first, operation.StatusProgress)
+
+	second := createTestOperation(t, operations)
+	if err := scheduler.Enqueue(second); err != nil {
+		t.Fatalf("ошибка постановки в очередь: %v", err)
+	}
+
+	if err := scheduler.Enqueue(createTestOperation(t, operations)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("ожидалась ErrQueueFull, получил %v", err)
+	}
+
+	close(release)
+	waitStatus(t, operations, first, operation.StatusDone)
+	waitStatus(t, operations, second, operation.StatusDone)
+
+	cancel()
+	scheduler.Wait()
+}
+
+func TestScheduler_Wait(t *testing.T) {
+	t.Run("необработанные операции получают ERROR", func(t *testing.T) {
+		scheduler, operations := newTestScheduler(newTestCache(t), ProcessorFunc(func(ctx context.Context, id string) error {
+			t.Error("воркеры не запущены, обработки быть не должно")
+			return nil
+		}), nil, 1, 10)
+
+		ids := []string{createTestOperation(t, operations), createTestOperation(t, operations)}
+		for _, id := range ids {
+			if err := scheduler.Enqueue(id); err != nil {
+				t.Fatalf("ошибка постановки в очередь: %v", err)
+			}
+		}
+
+		scheduler.Wait()
+
+		for _, id := range ids {
+			op, err := operations.Get(context.Background(), id)
+			if err != nil || op.Status != operation.StatusError || op.Error != ErrSchedulerStopped.Error() {
+				t.Errorf("ожидался статус ERROR с причиной остановки, получил %+v, %v", op, err)
+			}
+		}
+	})
+
+	t.Run("после остановки операции не принимаются", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		scheduler, _ := newTestScheduler(newTestCache(t), ProcessorFunc(func(ctx context.Context, id string) error {
+			return nil
+		}), nil, 1, 10)
+		scheduler.Start(ctx)
+		cancel()
+		scheduler.Wait()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for !errors.Is(scheduler.Enqueue(uuid.New().String()), ErrSchedulerStopped) {
+			if time.Now().After(deadline) {
+				t.Fatalf("ожидалась ErrSchedulerStopped")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
+
+// batchProcessor возвращает result для каждой операции, а Finalize завершает
+// соседей, которых больше некому ждать, как это делает обработка отчётов
+type batchProcessor struct {
+	operations *operation.Store
+	result     error
+	finalized  atomic.Int32
+}
+
+func (p *batchProcessor) Process(ctx context.Context, id string) error {
+	return p.result
+}
+
+func (p *batchProcessor) Finalize(ctx context.Context, id string) error {
+	p.finalized.Add(1)
+
+	ids, err := p.operations.Batch(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, sibling := range ids {
+		op, err := p.operations.Get(ctx, sibling)
+		if err != nil {
+			return err
+		}
+		if op.Status == operation.StatusNew {
+			return nil
+		}
+	}
+	for _, sibling := range ids {
+		if op, err := p.operations.Get(ctx, sibling); err == nil && op.Status == operation.StatusProgress {
+			if _, err = p.operations.Transition(ctx, sibling, operation.StatusDone, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func createTestBatch(t *testing.T, operations *operation.Store, size int) []string {
+	t.Helper()
+
+	batch := uuid.New().String()
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = uuid.New().String()
+		if err := operations.Create(context.Background(), &operation.Operation{ID: ids[i], Filename: "test.pdf", Batch: batch}); err != nil {
+			t.Fatalf("ошибка создания операции: %v", err)
+		}
+	}
+	if err := operations.SaveBatch(context.Background(), batch, ids); err != nil {
+		t.Fatalf("ошибка сохранения запроса: %v", err)
+	}
+	return ids
+}
+
+func TestScheduler_FinalizeBatch(t *testing.T) {
+	t.Run("соседа по запросу остановили в очереди", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Два экземпляра сервиса с общим хранилищем: первый откладывает свою
+		// операцию, второй останавливается, не взяв соседнюю из очереди
+		cache := newTestCache(t)
+		processor := &batchProcessor{result: ErrDeferred}
+		running, operations := newTestScheduler(cache, processor, nil, 1, 10)
+		stopping, _ := newTestScheduler(cache, processor, nil, 1, 10)
+		processor.operations = operations
+		running.Start(ctx)
+
+		ids := createTestBatch(t, operations, 2)
+		if err := running.Enqueue(ids[0]); err != nil {
+			t.Fatalf("ошибка постановки в очередь: %v", err)
+		}
+		waitStatus(t, operations, ids[0], operation.StatusProgress)
+
+		if err := stopping.Enqueue(ids[1]); err != nil {
+			t.Fatalf("ошибка постановки в очередь: %v", err)
+		}
+		stopping.Wait()
+
+		waitStatus(t, operations, ids[1], operation.StatusError)
+		waitStatus(t, operations, ids[0], operation.StatusDone)
+
+		cancel()
+		running.Wait()
+	})
+
+	t.Run("соседа не удалось поставить в очередь", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		processor := &batchProcessor{result: ErrDeferred}
+		scheduler, operations := newTestScheduler(newTestCache(t), processor, nil, 1, 10)
+		processor.operations = operations
+		scheduler.Start(ctx)
+
+		ids := createTestBatch(t, operations, 2)
+		if err := scheduler.Enqueue(ids[0]); err != nil {
+			t.Fatalf("ошибка постановки в очередь: %v", err)
+		}
+		waitStatus(t, operations, ids[0], operation.StatusProgress)
+
+		scheduler.Fail(context.Background(), ids[1], ErrQueueFull.Error())
+
+		waitStatus(t, operations, ids[1], operation.StatusError)
+		waitStatus(t, operations, ids[0], operation.StatusDone)
+
+		cancel()
+		scheduler.Wait()
+	})
+
+	t.Run("успешная операция не завершает запрос повторно", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		processor := &batchProcessor{}
+		scheduler, operations := newTestScheduler(newTestCache(t), processor, nil, 1, 10)
+		processor.operations = operations
+		scheduler.Start(ctx)
+
+		id := createTestOperation(t, operations)
+		if err := scheduler.Enqueue(id); err != nil {
+			t.Fatalf("ошибка постановки в очередь: %v", err)
+		}
+		waitStatus(t, operations, id, operation.StatusDone)
+
+		cancel()
+		scheduler.Wait()
+
+		if got := processor.finalized.Load(); got != 0 {
+			t.Errorf("после успешной обработки Finalize не вызывается, вызван %d раз", got)
+		}
+	})
+}
+
+func TestScheduler_ProcessingSlots(t *testing.T) {
+	cache := newTestCache(t)
+	slots := user.NewConcurrencyLimiter(cache, config.Config{
+		Concurrency: config.Concurrency{Enabled: true, Processing: 1, LeaseTTL: 60},
+	})
+
+	t.Run("экземпляры вместе не превышают лимит", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			running atomic.Int64
+			peak    atomic.Int64
+		)
+		processor := ProcessorFunc(func(ctx context.Context, id string) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				current := peak.Load()
+				if n <= current || peak.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+
+		// Два экземпляра сервиса с общим хранилищем слотов
+		first, operations := newTestScheduler(cache, processor, slots, 2, 10)
+		second := NewScheduler(first.log, operations, processor, slots, config.Config{
+			Files: config.Files{Workers: 2, QueueSize: 10, MaxProcessingTime: 5},
+		})
+		second.slotWait = 5 * time.Millisecond
+		first.Start(ctx)
+		second.Start(ctx)
+
+		var ids []string
+		for i := 0; i < 6; i++ {
+			id := createTestOperation(t, operations)
+			scheduler := first
+			if i%2 == 1 {
+				scheduler = second
+			}
+			if err := scheduler.Enqueue(id); err != nil {
+				t.Fatalf("ошибка постановки в очередь: %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		for _, id := range ids {
+			waitStatus(t, operations, id, operation.StatusDone)
+		}
+		if peak.Load() != 1 {
+			t.Errorf("одновременно должна обрабатываться одна операция, было %d", peak.Load())
+		}
+
+		cancel()
+		first.Wait()
+		second.Wait()
+	})
+
+	t.Run("остановка во время ожидания слота", func(t *testing.T) {
+		// Слот занят другим экземпляром
+		slot, err := slots.AcquireProcessing(context.Background())
+		if err != nil {
+			t.Fatalf("ошибка захвата слота: %v", err)
+		}
+		defer slot.Release(context.Background())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		scheduler, operations := newTestScheduler(cache, ProcessorFunc(func(ctx context.Context, id string) error {
+			t.Error("без слота обработки быть не должно")
+			return nil
+		}), slots, 1, 10)
+		scheduler.Start(ctx)
+
+		id := createTestOperation(t, operations)
+		if err = scheduler.Enqueue(id); err != nil {
+			t.Fatalf("ошибка постановки в очередь: %v", err)
+		}
+
+		// Воркер забрал операцию и ждёт слот
+		deadline := time.Now().Add(5 * time.Second)
+		for len(scheduler.queue) > 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		scheduler.Wait()
+		waitStatus(t, operations, id, operation.StatusError)
+	})
+}
+
+// Воркеры, очередь и счётчики не должны гоняться между собой
+func TestScheduler_Concurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scheduler, operations := newTestScheduler(newTestCache(t), ProcessorFunc(func(ctx context.Context, id string) error {
+		return nil
+	}), nil, 4, 100)
+	scheduler.Start(ctx)
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids []string
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := uuid.New().String()
+			if err := operations.Create(context.Background(), &operation.Operation{ID: id}); err != nil {
+				t.Errorf("ошибка создания операции: %v", err)
+				return
+			}
+			if err := scheduler.Enqueue(id); err != nil {
+				t.Errorf("ошибка постановки в очередь: %v", err)
+				return
+			}
+			mu.Lock()
+			ids = append(ids, id)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		waitStatus(t, operations, id, operation.StatusDone)
+	}
+
+	cancel()
+	scheduler.Wait()
+}
@@ -0,0 +1,189 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+	"github.com/google/uuid"
+)
+
+// Каталог, в котором хранятся загруженные файлы
+const filesDir = "./files"
+
+// Отчёт хранится рядом с загруженным файлом: ./files/<uuid>.report.pdf
+const reportSuffix = ".report.pdf"
+
+// Сигнатура в начале любого PDF-файла
+const pdfSignature = "%PDF-"
+
+var pdfMagic = []byte(pdfSignature)
+
+// SignatureLen — сколько первых байт файла нужно IsPDF
+const SignatureLen = len(pdfSignature)
+
+var (
+	ErrNotPDF       = errors.New("file is not a PDF document")
+	ErrFileTooLarge = errors.New("file is too large")
+)
+
+// Storage хранит загруженные файлы и отчёты на диске. Записи об операциях
+// ведёт operation.Store.
+type Storage struct {
+	filesDir string
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		filesDir: filesDir,
+	}
+}
+
+// Upload — файл, записанный на диск, но ещё не зарегистрированный как операция
+type Upload struct {
+	ID       string
+	Filename string
+	Size     int64
+	// SHA-256 содержимого
+	Hash []byte
+}
+
+// Write потоково записывает файл как ./files/<uuid>.pdf, проверяя сигнатуру PDF
+// и размер (maxSize <= 0 — без ограничения), и считает SHA-256 по ходу записи.
+// Частично записанный файл при ошибке удаляется.
+func (s *Storage) Write(filename string, src io.Reader, maxSize int64) (Upload, error) {
+	if err := os.MkdirAll(s.filesDir, 0o750); err != nil {
+		return Upload{}, fmt.Errorf("error creating files directory: %w", err)
+	}
+
+	header := make([]byte, len(pdfMagic))
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header, pdfMagic) {
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return Upload{}, fmt.Errorf("error reading file: %w", err)
+		}
+		return Upload{}, ErrNotPDF
+	}
+
+	upload := Upload{ID: uuid.New().String(), Filename: filename}
+	path := filepath.Join(s.filesDir, upload.ID+".pdf")
+
+	dst, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Upload{}, fmt.Errorf("error creating file: %w", err)
+	}
+
+	fail := func(err error) (Upload, error) {
+		_ = dst.Close()
+		_ = os.Remove(path)
+		return Upload{}, err
+	}
+
+	// Читаем на байт больше лимита, чтобы отличить файл ровно в лимит от превышения
+	body := io.MultiReader(bytes.NewReader(header), src)
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	hash := sha256.New()
+	upload.Size, err = io.Copy(io.MultiWriter(dst, hash), body)
+	if err != nil {
+		return fail(fmt.Errorf("error writing file: %w", err))
+	}
+	if maxSize > 0 && upload.Size > maxSize {
+		return fail(ErrFileTooLarge)
+	}
+
+	if err = dst.Close(); err != nil {
+		_ = os.Remove(path)
+		return Upload{}, fmt.Errorf("error closing file: %w", err)
+	}
+
+	upload.Hash = hash.Sum(nil)
+	return upload, nil
+}
+
+// IsPDF проверяет сигнатуру PDF в первых байтах файла
+func IsPDF(head []byte) bool {
+	return bytes.HasPrefix(head, pdfMagic)
+}
+
+// Remove удаляет файлы, записанные Write, если запрос не удалось завершить
+func (s *Storage) Remove(uploads []Upload) {
+	for _, u := range uploads {
+		_ = os.Remove(filepath.Join(s.filesDir, u.ID+".pdf"))
+	}
+}
+
+// Open открывает файл операции для чтения
+func (s *Storage) Open(id string) (*os.File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, operation.ErrNotFound
+	}
+	return os.Open(filepath.Join(s.filesDir, id+".pdf"))
+}
+
+// OpenReport открывает сгенерированный отчёт операции
+func (s *Storage) OpenReport(id string) (*os.File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, operation.ErrNotFound
+	}
+	return os.Open(filepath.Join(s.filesDir, id+reportSuffix))
+}
+
+// SaveReport записывает отчёт через временный файл, чтобы /get не отдал его недописанным
+func (s *Storage) SaveReport(id string, write func(io.Writer) error) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return operation.ErrNotFound
+	}
+
+	tmp, err := os.CreateTemp(s.filesDir, id+".report-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+
+	if err = write(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error writing report: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error closing report file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), filepath.Join(s.filesDir, id+reportSuffix)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error saving report: %w", err)
+	}
+
+	return nil
+}
+
+// Verify проверяет, что файл операции на месте и является PDF
+func (s *Storage) Verify(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, err := s.Open(id)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := make([]byte, len(pdfMagic))
+	if _, err = io.ReadFull(f, header); err != nil || !bytes.Equal(header, pdfMagic) {
+		return ErrNotPDF
+	}
+
+	return nil
+}
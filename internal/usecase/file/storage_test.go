@@ -0,0 +1,92 @@
+package file
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorage_Write(t *testing.T) {
+	t.Run("Файл сохраняется с хешем и размером", func(t *testing.T) {
+		s := &Storage{filesDir: t.TempDir()}
+		content := "%PDF-1.7 содержимое"
+
+		upload, err := s.Write("report.pdf", strings.NewReader(content), 1024)
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+
+		if upload.Size != int64(len(content)) {
+			t.Errorf("ожидался размер %d, получил %d", len(content), upload.Size)
+		}
+		if sum := sha256.Sum256([]byte(content)); string(upload.Hash) != string(sum[:]) {
+			t.Errorf("неверный SHA-256")
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.filesDir, upload.ID+".pdf"))
+		if err != nil || string(data) != content {
+			t.Errorf("содержимое файла не совпадает: %q, %v", data, err)
+		}
+	})
+
+	t.Run("Не PDF отклоняется без записи на диск", func(t *testing.T) {
+		s := &Storage{filesDir: t.TempDir()}
+
+		if _, err := s.Write("image.png", strings.NewReader("\x89PNG...."), 1024); !errors.Is(err, ErrNotPDF) {
+			t.Errorf("ожидалась ErrNotPDF, получил %v", err)
+		}
+		assertEmptyDir(t, s.filesDir)
+	})
+
+	t.Run("Превышение размера удаляет частично записанный файл", func(t *testing.T) {
+		s := &Storage{filesDir: t.TempDir()}
+
+		_, err := s.Write("big.pdf", strings.NewReader("%PDF-"+strings.Repeat("x", 100)), 50)
+		if !errors.Is(err, ErrFileTooLarge) {
+			t.Errorf("ожидалась ErrFileTooLarge, получил %v", err)
+		}
+		assertEmptyDir(t, s.filesDir)
+	})
+
+	t.Run("Файл ровно в лимит принимается", func(t *testing.T) {
+		s := &Storage{filesDir: t.TempDir()}
+
+		if _, err := s.Write("exact.pdf", strings.NewReader("%PDF-"+strings.Repeat("x", 45)), 50); err != nil {
+			t.Errorf("неожиданная ошибка: %v", err)
+		}
+	})
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("не удалось прочитать каталог: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ожидался пустой каталог, найдено файлов: %d", len(entries))
+	}
+}
+
+func TestIsPDF(t *testing.T) {
+	tests := []struct {
+		head string
+		want bool
+	}{
+		{"%PDF-1.7", true},
+		{"%PDF-", true},
+		{"%PDF", false},
+		{"\x89PNG\r\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPDF([]byte(tt.head)); got != tt.want {
+			t.Errorf("IsPDF(%q): ожидалось %v, получил %v", tt.head, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+)
+
+var (
+	ErrKeyConflict = errors.New("idempotency key already used with a different request")
+	ErrInProgress  = errors.New("request with this idempotency key is still in progress")
+)
+
+// Состояния записи идемпотентности
+const (
+	stateInProgress = "in_progress"
+	stateCompleted  = "completed"
+)
+
+type record struct {
+	Fingerprint string    `json:"fingerprint"`
+	State       string    `json:"state"`
+	IDs         []string  `json:"ids,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Store привязывает X-Operation-Key к набору файлов и хранит исходный ответ
+type Store struct {
+	cache memcached.CacheInterface
+	ttl   time.Duration
+}
+
+func NewStore(cache memcached.CacheInterface, cfg config.Config) *Store {
+	return &Store{
+		cache: cache,
+		ttl:   time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+	}
+}
+
+// Fingerprint считает SHA-256 от числа файлов и их хешей.
+// Порядок файлов не важен: ключ привязан к набору, а не к последовательности.
+// Адрес клиента в отпечаток не входит: повтор после смены сети или через
+// другой прокси пришёл бы с другого IP и получил бы 409. Владельца ключа,
+// когда появится аутентификация, нужно учитывать отдельно от отпечатка.
+func Fingerprint(fileHashes [][]byte) string {
+	hashes := make([]string, 0, len(fileHashes))
+	for _, h := range fileHashes {
+		hashes = append(hashes, hex.EncodeToString(h))
+	}
+	slices.Sort(hashes)
+
+	sum := sha256.New()
+	sum.Write([]byte(strconv.Itoa(len(hashes))))
+	for _, h := range hashes {
+		sum.Write([]byte{0})
+		sum.Write([]byte(h))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Reserve атомарно занимает ключ. Если ключ уже занят тем же запросом и он завершён,
+// возвращаются сохранённые ids для повтора ответа. Если ключ свободен — (nil, nil).
+func (s *Store) Reserve(ctx context.Context, key, fingerprint string) ([]string, error) {
+	data, err := json.Marshal(record{
+		Fingerprint: fingerprint,
+		State:       stateInProgress,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling idempotency record: %w", err)
+	}
+
+	// Вторая попытка нужна, если запись истекла между Add и Get
+	for attempt := 0; attempt < 2; attempt++ {
+		err = s.cache.Add(ctx, cacheKey(key), data, s.ttl)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, memcached.ErrNotStored) {
+			return nil, fmt.Errorf("error reserving idempotency key: %w", err)
+		}
+
+		existing, err := s.load(ctx, key)
+		if errors.Is(err, memcached.ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.Fingerprint != fingerprint {
+			return nil, ErrKeyConflict
+		}
+		if existing.State != stateCompleted {
+			return nil, ErrInProgress
+		}
+		return existing.IDs, nil
+	}
+
+	return nil, ErrInProgress
+}
+
+// Complete сохраняет ответ исходного запроса для последующих повторов
+func (s *Store) Complete(ctx context.Context, key, fingerprint string, ids []string) error {
+	existing, err := s.load(ctx, key)
+	if err != nil {
+		return err
+	}
+	if existing.Fingerprint != fingerprint {
+		return ErrKeyConflict
+	}
+
+	existing.State = stateCompleted
+	existing.IDs = ids
+
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return fmt.Errorf("error marshalling idempotency record: %w", err)
+	}
+
+	if err = s.cache.Set(ctx, cacheKey(key), data, s.ttl); err != nil {
+		return fmt.Errorf("error saving idempotency record: %w", err)
+	}
+	return nil
+}
+
+// Release освобождает ключ, если запрос не удалось выполнить, чтобы клиент мог повторить его
+func (s *Store) Release(ctx context.Context, key string) error {
+	if err := s.cache.Delete(ctx, cacheKey(key)); err != nil && !errors.Is(err, memcached.ErrCacheMiss) {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) load(ctx context.Context, key string) (record, error) {
+	var rec record
+
+	data, err := s.cache.Get(ctx, cacheKey(key))
+	if err != nil {
+		return rec, err
+	}
+
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("error unmarshalling idempotency record: %w", err)
+	}
+	return rec, nil
+}
+
+func cacheKey(key string) string {
+	return "idempotency:" + key
+}
@@ -0,0 +1,192 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Мок для memcached с поддержкой Add и Delete
+type mockCache struct {
+	storage  map[string][]byte
+	versions map[string]uint64
+	version  uint64
+}
+
+func newMockCache() *mockCache {
+	return &mockCache{
+		storage:  make(map[string][]byte),
+		versions: make(map[string]uint64),
+	}
+}
+
+func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, exists := m.storage[key]
+	if !exists {
+		return nil, memcache.ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (m *mockCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+func (m *mockCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, exists := m.storage[key]; exists {
+		return memcache.ErrNotStored
+	}
+	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+func (m *mockCache) Delete(ctx context.Context, key string) error {
+	if _, exists := m.storage[key]; !exists {
+		return memcache.ErrCacheMiss
+	}
+	delete(m.storage, key)
+	return nil
+}
+
+func (m *mockCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (m *mockCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (m *mockCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	value, exists := m.storage[key]
+	if !exists {
+		return nil, 0, memcache.ErrCacheMiss
+	}
+	return value, m.versions[key], nil
+}
+
+func (m *mockCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	if _, exists := m.storage[key]; !exists {
+		return memcache.ErrCacheMiss
+	}
+	if m.versions[key] != version {
+		return memcache.ErrCASConflict
+	}
+	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+// bump выдаёт ключу новую версию, как при любой записи в memcached
+func (m *mockCache) bump(key string) {
+	m.version++
+	m.versions[key] = m.version
+}
+
+func (m *mockCache) Ping() error {
+	return nil
+}
+
+func (m *mockCache) Close() error {
+	return nil
+}
+
+func newTestStore() *Store {
+	return NewStore(newMockCache(), config.Config{
+		Memcached: config.Memcached{DefaultTTL: 3600},
+	})
+}
+
+func TestStore_Reserve(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+
+	fingerprint := Fingerprint([][]byte{[]byte("file1")})
+
+	t.Run("первый запрос резервирует ключ", func(t *testing.T) {
+		ids, err := store.Reserve(ctx, "key1", fingerprint)
+		if err != nil || ids != nil {
+			t.Fatalf("ожидался (nil, nil), получил (%v, %v)", ids, err)
+		}
+	})
+
+	t.Run("повтор до завершения — в процессе", func(t *testing.T) {
+		_, err := store.Reserve(ctx, "key1", fingerprint)
+		if !errors.Is(err, ErrInProgress) {
+			t.Errorf("ожидалась ErrInProgress, получил %v", err)
+		}
+	})
+
+	t.Run("повтор после завершения возвращает те же ids", func(t *testing.T) {
+		if err := store.Complete(ctx, "key1", fingerprint, []string{"id1", "id2"}); err != nil {
+			t.Fatalf("ошибка Complete: %v", err)
+		}
+
+		ids, err := store.Reserve(ctx, "key1", fingerprint)
+		if err != nil {
+			t.Fatalf("ожидался nil, получил %v", err)
+		}
+		if !slices.Equal(ids, []string{"id1", "id2"}) {
+			t.Errorf("ожидались сохранённые ids, получил %v", ids)
+		}
+	})
+
+	t.Run("другой набор файлов — конфликт", func(t *testing.T) {
+		other := Fingerprint([][]byte{[]byte("file2")})
+		_, err := store.Reserve(ctx, "key1", other)
+		if !errors.Is(err, ErrKeyConflict) {
+			t.Errorf("ожидалась ErrKeyConflict, получил %v", err)
+		}
+	})
+
+	t.Run("повтор файла — конфликт", func(t *testing.T) {
+		other := Fingerprint([][]byte{[]byte("file1"), []byte("file1")})
+		_, err := store.Reserve(ctx, "key1", other)
+		if !errors.Is(err, ErrKeyConflict) {
+			t.Errorf("ожидалась ErrKeyConflict, получил %v", err)
+		}
+	})
+}
+
+func TestStore_Release(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+
+	fingerprint := Fingerprint([][]byte{[]byte("file1")})
+
+	if _, err := store.Reserve(ctx, "key1", fingerprint); err != nil {
+		t.Fatalf("ошибка Reserve: %v", err)
+	}
+	if err := store.Release(ctx, "key1"); err != nil {
+		t.Fatalf("ошибка Release: %v", err)
+	}
+
+	// После освобождения ключ можно занять снова
+	ids, err := store.Reserve(ctx, "key1", fingerprint)
+	if err != nil || ids != nil {
+		t.Errorf("ожидался (nil, nil), получил (%v, %v)", ids, err)
+	}
+}
+
+func TestFingerprint_OrderIndependent(t *testing.T) {
+	a := Fingerprint([][]byte{[]byte("a"), []byte("b")})
+	b := Fingerprint([][]byte{[]byte("b"), []byte("a")})
+	if a != b {
+		t.Errorf("отпечаток не должен зависеть от порядка файлов")
+	}
+}
+
+func TestFingerprint_Count(t *testing.T) {
+	one := Fingerprint([][]byte{[]byte("a")})
+	two := Fingerprint([][]byte{[]byte("a"), []byte("a")})
+	if one == two {
+		t.Errorf("отпечаток должен зависеть от числа файлов")
+	}
+}
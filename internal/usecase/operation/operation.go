@@ -0,0 +1,87 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status — состояние операции
+type Status string
+
+const (
+	StatusNew        Status = "NEW"
+	StatusProgress   Status = "PROGRESS"
+	StatusDone       Status = "DONE"
+	StatusError      Status = "ERROR"
+	StatusDownloaded Status = "DOWNLOADED"
+)
+
+// SchemaVersion — версия формата записи в кэше. Увеличивается при несовместимых
+// изменениях Operation, чтобы старые записи можно было распознать.
+const SchemaVersion = 1
+
+var (
+	ErrNotFound          = errors.New("operation not found")
+	ErrAlreadyExists     = errors.New("operation already exists")
+	ErrInvalidTransition = errors.New("invalid operation status transition")
+	ErrUnsupportedSchema = errors.New("unsupported operation schema version")
+)
+
+// Допустимые переходы: NEW → PROGRESS → DONE → DOWNLOADED, из NEW и PROGRESS — в ERROR.
+// Переход в тот же статус разрешён и ничего не меняет: несколько воркеров
+// могут завершать одну операцию, а отчёт — скачиваться повторно.
+var transitions = map[Status][]Status{
+	StatusNew:      {StatusProgress, StatusError},
+	StatusProgress: {StatusDone, StatusError},
+	StatusDone:     {StatusDownloaded},
+}
+
+// Operation — запись об обработке одного загруженного файла
+type Operation struct {
+	SchemaVersion  int       `json:"schema_version"`
+	ID             string    `json:"id"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	Owner          string    `json:"owner,omitempty"`
+	Filename       string    `json:"filename"`
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256"`
+	Batch          string    `json:"batch,omitempty"`
+	Status         Status    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Ready сообщает, что отчёт по операции готов
+func (o *Operation) Ready() bool {
+	return o.Status == StatusDone || o.Status == StatusDownloaded
+}
+
+// TransitionError — попытка недопустимого перехода, например DONE → PROGRESS
+type TransitionError struct {
+	ID   string
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("operation %s: cannot change status from %s to %s", e.ID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// CanTransition проверяет, допустим ли переход from → to
+func CanTransition(from, to Status) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/google/uuid"
+)
+
+// Store хранит операции в кэше и следит за допустимостью смены статусов
+type Store struct {
+	cache memcached.CacheInterface
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+func NewStore(cache memcached.CacheInterface, cfg config.Config) *Store {
+	return &Store{
+		cache: cache,
+		ttl:   time.Duration(cfg.Memcached.DefaultTTL) * time.Second,
+		now:   time.Now,
+	}
+}
+
+// Create сохраняет новую операцию со статусом NEW
+func (s *Store) Create(ctx context.Context, op *Operation) error {
+	now := s.now().UTC()
+	op.SchemaVersion = SchemaVersion
+	op.Status = StatusNew
+	op.Error = ""
+	op.CreatedAt = now
+	op.UpdatedAt = now
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("error marshalling operation: %w", err)
+	}
+
+	if err = s.cache.Add(ctx, operationKey(op.ID), data, s.ttl); err != nil {
+		if errors.Is(err, memcached.ErrNotStored) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("error saving operation: %w", err)
+	}
+
+	metrics.UpdateOperationStatus(string(StatusNew))
+	return nil
+}
+
+// Get возвращает операцию или ErrNotFound
+func (s *Store) Get(ctx context.Context, id string) (*Operation, error) {
+	// Идентификатор приходит от клиента, поэтому проверяем формат до обращения к кэшу
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := s.cache.Get(ctx, operationKey(id))
+	if err != nil {
+		if errors.Is(err, memcached.ErrCacheMiss) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting operation: %w", err)
+	}
+
+	return decode(data)
+}
+
+// Transition переводит операцию в статус to. Недопустимый переход возвращает
+// *TransitionError, параллельные изменения разрешаются через CompareAndSwap.
+func (s *Store) Transition(ctx context.Context, id string, to Status, errMessage string) (*Operation, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	var (
+		result  *Operation
+		changed bool
+	)
+	err := memcached.Update(ctx, s.cache, operationKey(id), s.ttl, func(data []byte) ([]byte, error) {
+		op, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		if !CanTransition(op.Status, to) {
+			return nil, &TransitionError{ID: id, From: op.Status, To: to}
+		}
+
+		result, changed = op, op.Status != to
+		if !changed {
+			// Повторный переход ничего не меняет, но запись всё равно
+			// перезаписывается: CAS подтверждает, что статус прочитан актуальным
+			return data, nil
+		}
+
+		op.Status = to
+		op.Error = errMessage
+		op.UpdatedAt = s.now().UTC()
+		return json.Marshal(op)
+	})
+	if err != nil {
+		if errors.Is(err, memcached.ErrCacheMiss) {
+			return nil, ErrNotFound
+		}
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error updating operation: %w", err)
+	}
+
+	if changed {
+		metrics.UpdateOperationStatus(string(to))
+	}
+	return result, nil
+}
+
+// Delete удаляет операцию. Отсутствующая операция ошибкой не считается.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	err := s.cache.Delete(ctx, operationKey(id))
+	if err != nil && !errors.Is(err, memcached.ErrCacheMiss) {
+		return fmt.Errorf("error deleting operation: %w", err)
+	}
+	return nil
+}
+
+// SaveBatch запоминает операции, загруженные одним запросом
+func (s *Store) SaveBatch(ctx context.Context, batch string, ids []string) error {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("error marshalling batch: %w", err)
+	}
+
+	if err = s.cache.Set(ctx, batchKey(batch), data, s.ttl); err != nil {
+		return fmt.Errorf("error saving batch: %w", err)
+	}
+	return nil
+}
+
+// Batch возвращает все операции из того же запроса, что и id, включая её саму
+func (s *Store) Batch(ctx context.Context, id string) ([]string, error) {
+	op, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Batch == "" {
+		return []string{id}, nil
+	}
+
+	data, err := s.cache.Get(ctx, batchKey(op.Batch))
+	if err != nil {
+		if errors.Is(err, memcached.ErrCacheMiss) {
+			return []string{id}, nil
+		}
+		return nil, fmt.Errorf("error getting batch: %w", err)
+	}
+
+	var ids []string
+	if err = json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("error unmarshalling batch: %w", err)
+	}
+	return ids, nil
+}
+
+func decode(data []byte) (*Operation, error) {
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("error unmarshalling operation: %w", err)
+	}
+	if op.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSchema, op.SchemaVersion)
+	}
+	return &op, nil
+}
+
+func operationKey(id string) string {
+	return "operation:" + id
+}
+
+func batchKey(batch string) string {
+	return "batch:" + batch
+}
@@ -0,0 +1,218 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/google/uuid"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Config{}
+	cfg.Memcached.DefaultTTL = 60
+	return NewStore(memcached.NewMemoryCache(ctx, cfg), cfg)
+}
+
+func createOperation(t *testing.T, store *Store, batch string) string {
+	t.Helper()
+
+	id := uuid.New().String()
+	err := store.Create(context.Background(), &Operation{ID: id, Filename: "test.pdf", Size: 10, Batch: batch})
+	if err != nil {
+		t.Fatalf("ошибка создания операции: %v", err)
+	}
+	return id
+}
+
+func TestStore_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("новая операция получает статус NEW", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		op, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("ошибка получения операции: %v", err)
+		}
+		if op.Status != StatusNew {
+			t.Errorf("ожидался статус %s, получил %s", StatusNew, op.Status)
+		}
+		if op.Filename != "test.pdf" || op.SchemaVersion != SchemaVersion {
+			t.Errorf("операция сохранена неверно: %+v", op)
+		}
+	})
+
+	t.Run("повторное создание отклоняется", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		err := store.Create(ctx, &Operation{ID: id})
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("ожидалась ErrAlreadyExists, получил %v", err)
+		}
+	})
+}
+
+func TestStore_Get(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	t.Run("несуществующая операция", func(t *testing.T) {
+		if _, err := store.Get(ctx, uuid.New().String()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ожидалась ErrNotFound, получил %v", err)
+		}
+	})
+
+	t.Run("некорректный идентификатор", func(t *testing.T) {
+		if _, err := store.Get(ctx, "../etc/passwd"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ожидалась ErrNotFound, получил %v", err)
+		}
+	})
+}
+
+func TestStore_Transition(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("полный жизненный цикл", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		for _, status := range []Status{StatusProgress, StatusDone, StatusDownloaded} {
+			op, err := store.Transition(ctx, id, status, "")
+			if err != nil {
+				t.Fatalf("переход в %s: %v", status, err)
+			}
+			if op.Status != status {
+				t.Errorf("ожидался статус %s, получил %s", status, op.Status)
+			}
+		}
+	})
+
+	t.Run("ошибка сохраняет сообщение", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		if _, err := store.Transition(ctx, id, StatusError, "broken pdf"); err != nil {
+			t.Fatalf("ошибка перехода: %v", err)
+		}
+
+		op, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("ошибка получения операции: %v", err)
+		}
+		if op.Status != StatusError || op.Error != "broken pdf" {
+			t.Errorf("ожидался ERROR с сообщением, получил %s %q", op.Status, op.Error)
+		}
+	})
+
+	t.Run("недопустимый переход", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		for _, status := range []Status{StatusProgress, StatusDone} {
+			if _, err := store.Transition(ctx, id, status, ""); err != nil {
+				t.Fatalf("переход в %s: %v", status, err)
+			}
+		}
+
+		_, err := store.Transition(ctx, id, StatusProgress, "")
+		if !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("ожидалась ErrInvalidTransition, получил %v", err)
+		}
+
+		var transitionErr *TransitionError
+		if !errors.As(err, &transitionErr) || transitionErr.From != StatusDone || transitionErr.To != StatusProgress {
+			t.Errorf("ожидалась TransitionError DONE → PROGRESS, получил %v", err)
+		}
+
+		op, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("ошибка получения операции: %v", err)
+		}
+		if op.Status != StatusDone {
+			t.Errorf("статус не должен меняться, получил %s", op.Status)
+		}
+	})
+
+	t.Run("повторный переход в тот же статус", func(t *testing.T) {
+		store := newTestStore(t)
+		id := createOperation(t, store, "")
+
+		first, err := store.Transition(ctx, id, StatusProgress, "")
+		if err != nil {
+			t.Fatalf("ошибка перехода: %v", err)
+		}
+		second, err := store.Transition(ctx, id, StatusProgress, "")
+		if err != nil {
+			t.Fatalf("ошибка повторного перехода: %v", err)
+		}
+		if !second.UpdatedAt.Equal(first.UpdatedAt) {
+			t.Errorf("повторный переход не должен менять запись")
+		}
+	})
+
+	t.Run("несуществующая операция", func(t *testing.T) {
+		store := newTestStore(t)
+
+		if _, err := store.Transition(ctx, uuid.New().String(), StatusProgress, ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ожидалась ErrNotFound, получил %v", err)
+		}
+	})
+}
+
+func TestStore_Batch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	batch := uuid.New().String()
+	ids := []string{createOperation(t, store, batch), createOperation(t, store, batch)}
+	if err := store.SaveBatch(ctx, batch, ids); err != nil {
+		t.Fatalf("ошибка сохранения запроса: %v", err)
+	}
+
+	got, err := store.Batch(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("ошибка получения запроса: %v", err)
+	}
+	if !slices.Equal(got, ids) {
+		t.Errorf("ожидалось %v, получил %v", ids, got)
+	}
+
+	single := createOperation(t, store, "")
+	if got, err = store.Batch(ctx, single); err != nil || !slices.Equal(got, []string{single}) {
+		t.Errorf("операция без запроса должна возвращать саму себя, получил %v, %v", got, err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusNew, StatusProgress, true},
+		{StatusNew, StatusError, true},
+		{StatusNew, StatusDone, false},
+		{StatusProgress, StatusDone, true},
+		{StatusDone, StatusDownloaded, true},
+		{StatusDone, StatusError, false},
+		{StatusError, StatusProgress, false},
+		{StatusDownloaded, StatusDone, false},
+		{StatusDownloaded, StatusDownloaded, true},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, ожидалось %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Caritas-Team/reviewer/internal/chart"
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+)
+
+var ErrIndicatorNotFound = errors.New("indicator not found")
+
+// ChartBuilder строит графики динамики показателей по результату сравнения
+type ChartBuilder struct {
+	width  int
+	height int
+	bands  []config.ReferenceBand
+}
+
+func NewChartBuilder(cfg config.Config) *ChartBuilder {
+	return &ChartBuilder{
+		width:  cfg.Charts.Width,
+		height: cfg.Charts.Height,
+		bands:  cfg.Charts.ReferenceBands,
+	}
+}
+
+// Build возвращает график по показателям names, по серии на каждый.
+// Без names на график попадают все показатели. Имена сравниваются без учёта регистра.
+func (b *ChartBuilder) Build(result *comparison.Result, names ...string) (chart.Chart, error) {
+	indicators := result.Indicators
+	if len(names) > 0 {
+		indicators = make([]comparison.Indicator, 0, len(names))
+		for _, name := range names {
+			ind, ok := findIndicator(result, name)
+			if !ok {
+				return chart.Chart{}, ErrIndicatorNotFound
+			}
+			indicators = append(indicators, ind)
+		}
+	}
+
+	c := chart.Chart{
+		Title:  "Динамика показателей",
+		XLabel: "Дата диагностики",
+		YLabel: "Значение",
+		Width:  b.width,
+		Height: b.height,
+	}
+	if len(indicators) == 1 {
+		c.Title = indicators[0].Name
+	}
+
+	for _, ind := range indicators {
+		series := chart.Series{Name: ind.Name}
+		for _, p := range ind.Points {
+			series.Points = append(series.Points, chart.Point{X: p.Date, Y: p.Value})
+		}
+		c.Series = append(c.Series, series)
+
+		for _, band := range b.bands {
+			if !strings.EqualFold(band.Indicator, ind.Name) {
+				continue
+			}
+
+			// На общем графике уточняем, к какому показателю относится полоса
+			label := band.Label
+			if len(indicators) > 1 {
+				label = ind.Name + ": " + label
+			}
+			c.Bands = append(c.Bands, chart.Band{Label: label, From: band.From, To: band.To})
+		}
+	}
+
+	return c, nil
+}
+
+func findIndicator(result *comparison.Result, name string) (comparison.Indicator, bool) {
+	for _, ind := range result.Indicators {
+		if strings.EqualFold(ind.Name, name) {
+			return ind, true
+		}
+	}
+	return comparison.Indicator{}, false
+}
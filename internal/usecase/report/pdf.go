@@ -0,0 +1,270 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/chart"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Шрифты Go покрывают кириллицу и встраиваются в PDF целиком
+const fontFamily = "Go"
+
+// Метка формата в метриках экспорта
+const reportFormat = "pdf"
+
+// Размеры в миллиметрах
+const (
+	pageMargin  = 15.0
+	rowHeight   = 7.0
+	chartHeight = 115.0
+)
+
+// Разрешение графиков: около 127 точек на дюйм
+const chartPixelsPerMM = 5.0
+
+const dateLayout = "02.01.2006"
+
+// Ширины колонок таблицы показателей, в сумме — ширина страницы A4 без полей
+var tableColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"Показатель", 65, "L"},
+	{"Дата", 25, "C"},
+	{"Значение", 25, "R"},
+	{"Изменение", 30, "R"},
+	{"Изменение, %", 35, "R"},
+}
+
+// PDFRenderer формирует PDF-отчёт: сводка, таблицы показателей, графики и выводы.
+// Графики рисует пакет chart, как и для GET /get/chart.
+type PDFRenderer struct {
+	charts *ChartBuilder
+}
+
+func NewPDFRenderer(charts *ChartBuilder) *PDFRenderer {
+	return &PDFRenderer{charts: charts}
+}
+
+// Render пишет отчёт по результату сравнения в w
+func (r *PDFRenderer) Render(w io.Writer, result *comparison.Result) error {
+	start := time.Now()
+	defer func() {
+		metrics.UpdateExportTime(reportFormat, time.Since(start).Seconds())
+	}()
+
+	pdf := newDocument()
+	r.summaryPage(pdf, result)
+	r.tablePages(pdf, result)
+	if err := r.chartPages(pdf, result); err != nil {
+		metrics.UpdateExportError(reportFormat)
+		return err
+	}
+
+	if err := pdf.Output(w); err != nil {
+		metrics.UpdateExportError(reportFormat)
+		return fmt.Errorf("error rendering pdf report: %w", err)
+	}
+
+	metrics.UpdateExportSuccess(reportFormat)
+	return nil
+}
+
+func newDocument() *fpdf.Fpdf {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.AliasNbPages("{nb}")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Страница %d из {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	return pdf
+}
+
+func (r *PDFRenderer) summaryPage(pdf *fpdf.Fpdf, result *comparison.Result) {
+	pdf.AddPage()
+
+	pdf.SetFont(fontFamily, "B", 18)
+	pdf.CellFormat(0, 12, "Отчёт о динамике развития", "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	dates := make([]string, 0, len(result.Dates))
+	for _, d := range result.Dates {
+		dates = append(dates, d.Format(dateLayout))
+	}
+
+	pdf.SetFont(fontFamily, "", 11)
+	summaryLine(pdf, "Ребёнок", result.ChildID)
+	summaryLine(pdf, "Диагностики", strings.Join(dates, ", "))
+	summaryLine(pdf, "Показателей", strconv.Itoa(len(result.Indicators)))
+	summaryLine(pdf, "Улучшения", listOrDash(result.Improvements))
+	summaryLine(pdf, "Ухудшения", listOrDash(result.Regressions))
+	pdf.Ln(6)
+
+	pdf.SetFont(fontFamily, "B", 14)
+	pdf.CellFormat(0, 9, "Выводы", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 11)
+
+	if len(result.Conclusions) == 0 {
+		pdf.MultiCell(0, 6, "Для выводов о динамике нужно минимум две диагностики с общими показателями.", "", "L", false)
+		return
+	}
+
+	for _, c := range result.Conclusions {
+		setKindColor(pdf, c.Kind)
+		pdf.MultiCell(0, 6, "• "+c.Text, "", "L", false)
+	}
+	pdf.SetTextColor(0, 0, 0)
+}
+
+func (r *PDFRenderer) tablePages(pdf *fpdf.Fpdf, result *comparison.Result) {
+	pdf.AddPage()
+
+	pdf.SetFont(fontFamily, "B", 14)
+	pdf.CellFormat(0, 9, "Показатели", "", 1, "L", false, 0, "")
+	tableHeader(pdf)
+
+	_, pageHeight := pdf.GetPageSize()
+	for _, ind := range result.Indicators {
+		for i, point := range ind.Points {
+			// Переносим строку на новую страницу вместе с заголовком таблицы
+			if pdf.GetY()+rowHeight > pageHeight-pageMargin-5 {
+				pdf.AddPage()
+				tableHeader(pdf)
+			}
+
+			change, percent := "", ""
+			if i > 0 {
+				delta := ind.Deltas[i-1]
+				change = signed(delta.Absolute)
+				if delta.Percent != nil {
+					percent = signed(*delta.Percent)
+				}
+			}
+
+			pdf.SetFont(fontFamily, "", 10)
+			cells := []string{
+				fitText(pdf, ind.Name, tableColumns[0].width-2),
+				point.Date.Format(dateLayout),
+				formatNumber(point.Value),
+				change,
+				percent,
+			}
+			for j, col := range tableColumns {
+				pdf.CellFormat(col.width, rowHeight, cells[j], "1", 0, col.align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+}
+
+func (r *PDFRenderer) chartPages(pdf *fpdf.Fpdf, result *comparison.Result) error {
+	left, top, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - left - right
+
+	for i, ind := range result.Indicators {
+		c, err := r.charts.Build(result, ind.Name)
+		if err != nil {
+			return err
+		}
+		// Размер в пикселях пропорционален месту на странице
+		c.Width = int(width * chartPixelsPerMM)
+		c.Height = int(chartHeight * chartPixelsPerMM)
+
+		var buf bytes.Buffer
+		if err = chart.RenderPNG(&buf, c); err != nil {
+			return fmt.Errorf("error rendering chart: %w", err)
+		}
+
+		// По два графика на страницу
+		if i%2 == 0 {
+			pdf.AddPage()
+		}
+		y := top + float64(i%2)*(chartHeight+10)
+
+		name := "chart-" + strconv.Itoa(i)
+		options := fpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, options, &buf)
+		pdf.ImageOptions(name, left, y, width, chartHeight, false, options, 0, "")
+	}
+
+	return nil
+}
+
+func tableHeader(pdf *fpdf.Fpdf) {
+	pdf.SetFont(fontFamily, "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range tableColumns {
+		pdf.CellFormat(col.width, rowHeight, col.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func summaryLine(pdf *fpdf.Fpdf, label, value string) {
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(40, 7, label+":", "", 0, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.MultiCell(0, 7, value, "", "L", false)
+}
+
+func setKindColor(pdf *fpdf.Fpdf, kind comparison.Kind) {
+	switch kind {
+	case comparison.KindImprovement:
+		pdf.SetTextColor(30, 120, 50)
+	case comparison.KindRegression:
+		pdf.SetTextColor(180, 40, 40)
+	default:
+		pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// fitText обрезает строку с многоточием, чтобы она поместилась в ячейку
+func fitText(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func listOrDash(items []string) string {
+	if len(items) == 0 {
+		return "—"
+	}
+	return strings.Join(items, ", ")
+}
+
+func signed(v float64) string {
+	if v > 0 {
+		return "+" + formatNumber(v)
+	}
+	return formatNumber(v)
+}
+
+func formatNumber(v float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(comparison.Round(v), 'f', -1, 64), ".", ",")
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/ledongthuc/pdf"
+)
+
+func TestPDFRenderer_Render(t *testing.T) {
+	march := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	result, err := comparison.NewComparator(config.Config{}).Compare([]*file.DiagnosticResult{
+		{OperationID: "op1", ChildID: "A-17", TestDate: march, Indicators: []file.Indicator{
+			{Name: "Речь", Value: 10},
+			{Name: "Моторика", Value: 4},
+			{Name: "Внимание", Value: 30},
+		}},
+		{OperationID: "op2", ChildID: "A-17", TestDate: may, Indicators: []file.Indicator{
+			{Name: "Речь", Value: 12},
+			{Name: "Моторика", Value: 3},
+			{Name: "Внимание", Value: 30},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка сравнения: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = NewPDFRenderer(NewChartBuilder(config.Config{})).Render(&buf, result); err != nil {
+		t.Fatalf("ошибка рендеринга: %v", err)
+	}
+
+	reader, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("отчёт не читается как PDF: %v", err)
+	}
+
+	// Сводка, таблица и две страницы графиков для трёх показателей
+	if pages := reader.NumPage(); pages != 4 {
+		t.Errorf("ожидалось 4 страницы, получил %d", pages)
+	}
+}
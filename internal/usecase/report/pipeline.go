@@ -0,0 +1,160 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Caritas-Team/reviewer/internal/logger"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/usecase/comparison"
+	"github.com/Caritas-Team/reviewer/internal/usecase/file"
+	"github.com/Caritas-Team/reviewer/internal/usecase/operation"
+)
+
+// Pipeline — обработчик операций для Scheduler: извлекает данные из PDF,
+// а когда обработаны все файлы запроса, строит отчёты и завершает операции
+type Pipeline struct {
+	storage     *file.Storage
+	operations  *operation.Store
+	extractor   *file.Extractor
+	comparisons *comparison.Service
+	renderer    *PDFRenderer
+	log         *logger.Logger
+}
+
+func NewPipeline(
+	log *logger.Logger,
+	storage *file.Storage,
+	operations *operation.Store,
+	extractor *file.Extractor,
+	comparisons *comparison.Service,
+	renderer *PDFRenderer,
+) *Pipeline {
+	return &Pipeline{
+		storage:     storage,
+		operations:  operations,
+		extractor:   extractor,
+		comparisons: comparisons,
+		renderer:    renderer,
+		log:         log,
+	}
+}
+
+// Process реализует file.Processor
+func (p *Pipeline) Process(ctx context.Context, id string) error {
+	if err := p.extractor.Process(ctx, id); err != nil {
+		// Ошибку фиксируем сразу, чтобы остальные операции запроса не ждали эту.
+		// Контекст задачи мог истечь, поэтому пишем без отмены.
+		finishCtx := context.WithoutCancel(ctx)
+		if _, serr := p.operations.Transition(finishCtx, id, operation.StatusError, err.Error()); serr != nil {
+			p.log.Error("cannot set operation status", "id", id, "err", serr)
+		}
+		if _, ferr := p.finalize(finishCtx, id); ferr != nil {
+			p.log.Error("cannot finalize batch", "id", id, "err", ferr)
+		}
+		return err
+	}
+
+	settled, err := p.finalize(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !settled {
+		return file.ErrDeferred
+	}
+	return nil
+}
+
+// Finalize реализует file.Finalizer: операция id завершилась ошибкой вне
+// Process, и отложенные соседи по запросу могут больше её не ждать
+func (p *Pipeline) Finalize(ctx context.Context, id string) error {
+	_, err := p.finalize(ctx, id)
+	return err
+}
+
+// finalize строит отчёты, если все операции запроса обработаны.
+// Каждая операция сохраняет свой результат до проверки соседей, поэтому
+// хотя бы одна из завершившихся последними увидит запрос целиком.
+func (p *Pipeline) finalize(ctx context.Context, id string) (bool, error) {
+	batch, err := p.operations.Batch(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	var pending []string
+	for _, sibling := range batch {
+		_, err := p.extractor.Result(ctx, sibling)
+		if err == nil {
+			if sibling != id {
+				pending = append(pending, sibling)
+			}
+			continue
+		}
+		if !errors.Is(err, memcached.ErrCacheMiss) {
+			return false, err
+		}
+		if sibling == id {
+			continue
+		}
+
+		op, err := p.operations.Get(ctx, sibling)
+		if errors.Is(err, operation.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if op.Status == operation.StatusError {
+			continue
+		}
+		return false, nil
+	}
+
+	// Собственная операция завершается через Scheduler по результату Process
+	if _, err = p.extractor.Result(ctx, id); err == nil {
+		if err = p.render(ctx, id); err != nil {
+			return true, err
+		}
+	}
+
+	for _, sibling := range pending {
+		op, err := p.operations.Get(ctx, sibling)
+		if err != nil || op.Status != operation.StatusProgress {
+			// Операцию уже завершил другой воркер
+			continue
+		}
+
+		if err = p.render(ctx, sibling); err != nil {
+			p.log.Error("cannot render report", "id", sibling, "err", err)
+			if _, err = p.operations.Transition(ctx, sibling, operation.StatusError, "report rendering failed"); err != nil {
+				p.log.Error("cannot set operation status", "id", sibling, "err", err)
+			}
+			continue
+		}
+
+		if _, err = p.operations.Transition(ctx, sibling, operation.StatusDone, ""); err != nil {
+			p.log.Error("cannot set operation status", "id", sibling, "err", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (p *Pipeline) render(ctx context.Context, id string) error {
+	result, err := p.comparisons.ForOperation(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error comparing diagnostics: %w", err)
+	}
+
+	err = p.storage.SaveReport(id, func(w io.Writer) error {
+		return p.renderer.Render(w, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.log.Info("report rendered", "id", id, "child_id", result.ChildID, "diagnostics", len(result.Dates))
+	return nil
+}
@@ -0,0 +1,179 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+	"github.com/Caritas-Team/reviewer/internal/metrics"
+)
+
+// Области ограничения одновременных операций, они же метки метрики
+const (
+	ScopeUpload     = "upload"
+	ScopeProcessing = "processing"
+)
+
+// Срок аренды слота по умолчанию: больше таймаута чтения и времени обработки
+const defaultLeaseTTL = 120 * time.Second
+
+// Через сколько повторить запрос, если слоты не удалось занять из-за
+// конкуренции за значение семафора
+const contentionRetryAfter = time.Second
+
+// Как часто занятость общих слотов перечитывается из хранилища для метрики
+const occupancyInterval = 15 * time.Second
+
+// Ключи общих слотов
+const (
+	uploadsKey    = "concurrency:uploads"
+	processingKey = "concurrency:processing"
+)
+
+// ConcurrencyLimiter ограничивает число одновременных загрузок клиента и всех
+// клиентов, а также число одновременно обрабатываемых операций. Запросы
+// пропускаются без ограничения, только если хранилище недоступно.
+type ConcurrencyLimiter struct {
+	semaphore   *memcached.Semaphore
+	enabled     bool
+	perIdentity int
+	uploads     int
+	processing  int
+}
+
+func NewConcurrencyLimiter(cache memcached.CacheInterface, cfg config.Config) *ConcurrencyLimiter {
+	ttl := time.Duration(cfg.Concurrency.LeaseTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+
+	return &ConcurrencyLimiter{
+		semaphore:   memcached.NewSemaphore(cache, ttl),
+		enabled:     cfg.Concurrency.Enabled,
+		perIdentity: cfg.Concurrency.PerIdentity,
+		uploads:     cfg.Concurrency.Uploads,
+		processing:  cfg.Concurrency.Processing,
+	}
+}
+
+// Slot — занятые слоты, освобождаются через Release
+type Slot struct {
+	semaphore *memcached.Semaphore
+	scope     string
+	leases    []memcached.Lease
+}
+
+// AcquireUpload занимает слот загрузки клиента identity и общий слот загрузок.
+// При нехватке возвращает ошибку с *memcached.SemaphoreFullError.
+func (l *ConcurrencyLimiter) AcquireUpload(ctx context.Context, identity string) (*Slot, error) {
+	slot := &Slot{semaphore: l.semaphore, scope: ScopeUpload}
+	if !l.enabled {
+		return slot, nil
+	}
+
+	// Сначала слот клиента: один клиент не должен занимать общие слоты
+	if l.perIdentity > 0 {
+		if err := l.acquire(ctx, slot, "concurrency:upload:"+identity, l.perIdentity); err != nil {
+			return nil, err
+		}
+	}
+	if l.uploads > 0 {
+		if err := l.acquire(ctx, slot, uploadsKey, l.uploads); err != nil {
+			slot.Release(context.WithoutCancel(ctx))
+			return nil, err
+		}
+	}
+	return slot, nil
+}
+
+// AcquireProcessing занимает общий слот обработки операции
+func (l *ConcurrencyLimiter) AcquireProcessing(ctx context.Context) (*Slot, error) {
+	slot := &Slot{semaphore: l.semaphore, scope: ScopeProcessing}
+	if !l.enabled || l.processing <= 0 {
+		return slot, nil
+	}
+
+	if err := l.acquire(ctx, slot, processingKey, l.processing); err != nil {
+		return nil, err
+	}
+	return slot, nil
+}
+
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, slot *Slot, key string, capacity int) error {
+	lease, err := l.semaphore.Acquire(ctx, key, capacity)
+	switch {
+	case err == nil:
+		slot.add(lease)
+		return nil
+	case errors.Is(err, memcached.ErrCASConflict):
+		// Попытки CAS исчерпаны: за слоты борется много запросов, то есть
+		// лимит как раз нужен — отвечаем так же, как при нехватке слотов
+		return &memcached.SemaphoreFullError{Key: key, RetryAfter: contentionRetryAfter}
+	case memcached.IsUnavailable(err):
+		// Недоступное хранилище не должно останавливать загрузки
+		return nil
+	default:
+		return err
+	}
+}
+
+// Start периодически обновляет метрику занятых слотов по данным хранилища,
+// чтобы все экземпляры показывали общую занятость, а не только свои аренды
+func (l *ConcurrencyLimiter) Start(ctx context.Context) {
+	if !l.enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(occupancyInterval)
+		defer ticker.Stop()
+
+		for {
+			l.reportOccupancy(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (l *ConcurrencyLimiter) reportOccupancy(ctx context.Context) {
+	for scope, key := range map[string]string{ScopeUpload: uploadsKey, ScopeProcessing: processingKey} {
+		if inUse, err := l.semaphore.Count(ctx, key); err == nil {
+			metrics.UpdateConcurrencySlots(scope, float64(inUse))
+		}
+	}
+}
+
+// add запоминает аренду. Lease.InUse прочитан из хранилища, поэтому
+// метрика отражает аренды всех экземпляров.
+func (s *Slot) add(lease memcached.Lease) {
+	s.leases = append(s.leases, lease)
+	if isGlobal(lease.Key) {
+		metrics.UpdateConcurrencySlots(s.scope, float64(lease.InUse))
+	}
+}
+
+// Release освобождает все слоты. Повторный вызов ничего не делает.
+func (s *Slot) Release(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	for _, lease := range s.leases {
+		inUse, err := s.semaphore.Release(ctx, lease)
+		if err == nil && isGlobal(lease.Key) {
+			metrics.UpdateConcurrencySlots(s.scope, float64(inUse))
+		}
+	}
+	s.leases = nil
+}
+
+// В метрику попадают только общие слоты: слоты клиентов раздули бы число меток
+func isGlobal(key string) bool {
+	return key == uploadsKey || key == processingKey
+}
@@ -0,0 +1,177 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+)
+
+func newTestConcurrencyLimiter(cache *mockCache) *ConcurrencyLimiter {
+	return NewConcurrencyLimiter(cache, config.Config{
+		Concurrency: config.Concurrency{
+			Enabled:     true,
+			PerIdentity: 1,
+			Uploads:     2,
+			Processing:  1,
+			LeaseTTL:    60,
+		},
+	})
+}
+
+func TestConcurrencyLimiter_AcquireUpload(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("лимит клиента", func(t *testing.T) {
+		limiter := newTestConcurrencyLimiter(newMockCache())
+
+		slot, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1")
+		if err != nil {
+			t.Fatalf("первая загрузка должна была пройти, получил %v", err)
+		}
+		if _, err = limiter.AcquireUpload(ctx, "ip:10.0.0.1"); !errors.Is(err, memcached.ErrSemaphoreFull) {
+			t.Errorf("ожидалась ErrSemaphoreFull, получил %v", err)
+		}
+
+		slot.Release(ctx)
+		if _, err = limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+			t.Errorf("после освобождения загрузка должна была пройти, получил %v", err)
+		}
+	})
+
+	t.Run("общий лимит", func(t *testing.T) {
+		cache := newMockCache()
+		limiter := newTestConcurrencyLimiter(cache)
+
+		for _, identity := range []string{"ip:10.0.0.1", "ip:10.0.0.2"} {
+			if _, err := limiter.AcquireUpload(ctx, identity); err != nil {
+				t.Fatalf("загрузка %s должна была пройти, получил %v", identity, err)
+			}
+		}
+		if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.3"); !errors.Is(err, memcached.ErrSemaphoreFull) {
+			t.Fatalf("ожидалась ErrSemaphoreFull, получил %v", err)
+		}
+
+		// Отказ по общему лимиту освобождает слот клиента
+		limiter.uploads = 3
+		if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.3"); err != nil {
+			t.Errorf("слот клиента не должен был остаться занятым, получил %v", err)
+		}
+	})
+
+	t.Run("выключен", func(t *testing.T) {
+		limiter := NewConcurrencyLimiter(newMockCache(), config.Config{})
+
+		for i := 0; i < 5; i++ {
+			if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+				t.Errorf("при выключенном лимите ошибок быть не должно, получил %v", err)
+			}
+		}
+	})
+
+	t.Run("кэш недоступен", func(t *testing.T) {
+		limiter := newTestConcurrencyLimiter(newBrokenCache())
+
+		slot, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1")
+		if err != nil {
+			t.Fatalf("при недоступном кэше загрузка должна проходить, получил %v", err)
+		}
+		slot.Release(ctx)
+	})
+
+	t.Run("конкуренция за слоты", func(t *testing.T) {
+		cache := newMockCache()
+		limiter := newTestConcurrencyLimiter(cache)
+		if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+			t.Fatalf("ошибка захвата: %v", err)
+		}
+
+		// Каждая запись проигрывает гонку другому экземпляру
+		limiter.semaphore = memcached.NewSemaphore(&conflictCache{mockCache: cache}, time.Minute)
+
+		_, err := limiter.AcquireUpload(ctx, "ip:10.0.0.2")
+		var full *memcached.SemaphoreFullError
+		if !errors.As(err, &full) || full.RetryAfter != contentionRetryAfter {
+			t.Errorf("ожидалась SemaphoreFullError с повтором через %v, получил %v", contentionRetryAfter, err)
+		}
+	})
+
+	t.Run("повреждённое значение", func(t *testing.T) {
+		cache := newMockCache()
+		limiter := newTestConcurrencyLimiter(cache)
+		if err := cache.Set(ctx, uploadsKey, []byte("garbage"), time.Minute); err != nil {
+			t.Fatalf("ошибка записи: %v", err)
+		}
+
+		// Хранилище ответило, пропускать запрос без ограничения нельзя
+		if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err == nil {
+			t.Errorf("ожидалась ошибка")
+		}
+
+		// Слот клиента освобождён вместе с ошибкой
+		if err := cache.Delete(ctx, uploadsKey); err != nil {
+			t.Fatalf("ошибка удаления: %v", err)
+		}
+		if _, err := limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+			t.Errorf("слот клиента должен был освободиться, получил %v", err)
+		}
+	})
+}
+
+// conflictCache проигрывает каждый CompareAndSwap
+type conflictCache struct {
+	*mockCache
+}
+
+func (c *conflictCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	return memcached.ErrCASConflict
+}
+
+func TestConcurrencyLimiter_Occupancy(t *testing.T) {
+	ctx := context.Background()
+	cache := newMockCache()
+
+	// Два экземпляра сервиса с общим хранилищем
+	first := newTestConcurrencyLimiter(cache)
+	second := newTestConcurrencyLimiter(cache)
+
+	if _, err := first.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+		t.Fatalf("ошибка захвата: %v", err)
+	}
+	if _, err := second.AcquireUpload(ctx, "ip:10.0.0.2"); err != nil {
+		t.Fatalf("ошибка захвата: %v", err)
+	}
+
+	for _, limiter := range []*ConcurrencyLimiter{first, second} {
+		if inUse, err := limiter.semaphore.Count(ctx, uploadsKey); err != nil || inUse != 2 {
+			t.Errorf("ожидалось 2 занятых слота, получил %d, %v", inUse, err)
+		}
+	}
+}
+
+func TestConcurrencyLimiter_AcquireProcessing(t *testing.T) {
+	ctx := context.Background()
+	limiter := newTestConcurrencyLimiter(newMockCache())
+
+	slot, err := limiter.AcquireProcessing(ctx)
+	if err != nil {
+		t.Fatalf("ошибка захвата слота обработки: %v", err)
+	}
+	if _, err = limiter.AcquireProcessing(ctx); !errors.Is(err, memcached.ErrSemaphoreFull) {
+		t.Errorf("ожидалась ErrSemaphoreFull, получил %v", err)
+	}
+
+	// Слоты загрузок и обработки не пересекаются
+	if _, err = limiter.AcquireUpload(ctx, "ip:10.0.0.1"); err != nil {
+		t.Errorf("загрузка не должна зависеть от обработки, получил %v", err)
+	}
+
+	slot.Release(ctx)
+	slot.Release(ctx)
+	if _, err = limiter.AcquireProcessing(ctx); err != nil {
+		t.Errorf("после освобождения слот должен был заняться, получил %v", err)
+	}
+}
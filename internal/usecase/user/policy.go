@@ -0,0 +1,154 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+	"github.com/Caritas-Team/reviewer/internal/memcached"
+)
+
+// Источники идентичности, по которой считается лимит
+const (
+	// IdentityIP — IP клиента
+	IdentityIP = "ip"
+	// IdentityOperationKey — заголовок X-Operation-Key
+	IdentityOperationKey = "operation_key"
+	// IdentityUser — аутентифицированный пользователь из контекста запроса
+	IdentityUser = "user"
+	// IdentityHeader — значение заголовка из настройки header
+	IdentityHeader = "header"
+)
+
+// Имя политики из общих настроек rate_limiter
+const DefaultPolicy = "default"
+
+// Policy — лимит для набора маршрутов. Если идентичность в запросе
+// отсутствует, лимит считается по IP клиента.
+type Policy struct {
+	Name     string
+	Routes   []string
+	Exempt   bool
+	Identity string
+	Header   string
+	Limiter  *RateLimiter
+}
+
+// Policies — политики маршрутов и политика по умолчанию для остальных запросов
+type Policies struct {
+	Default Policy
+	Rules   []Policy
+}
+
+// NewPolicies строит политики из конфига. У каждой политики свои счётчики,
+// незаданные поля берутся из общих настроек.
+func NewPolicies(cache memcached.CacheInterface, cfg config.Config) (*Policies, error) {
+	base := cfg.RateLimiter
+	if err := validateAlgorithm(DefaultPolicy, base.Algorithm); err != nil {
+		return nil, err
+	}
+
+	def := Policy{
+		Name:     DefaultPolicy,
+		Identity: base.Identity,
+		Header:   base.Header,
+		Limiter:  newRateLimiter(cache, DefaultPolicy, base),
+	}
+	if err := validateIdentity(&def); err != nil {
+		return nil, err
+	}
+
+	policies := &Policies{Default: def}
+	seen := map[string]bool{DefaultPolicy: true}
+
+	for _, rule := range base.Policies {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("error in rate limit policy: name is required")
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("error in rate limit policy %s: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if len(rule.Routes) == 0 {
+			return nil, fmt.Errorf("error in rate limit policy %s: routes are required", rule.Name)
+		}
+
+		limits := base
+		if rule.Algorithm != "" {
+			if err := validateAlgorithm(rule.Name, rule.Algorithm); err != nil {
+				return nil, err
+			}
+			limits.Algorithm = rule.Algorithm
+		}
+		if rule.RequestsPerWindow > 0 {
+			limits.RequestsPerWindow = rule.RequestsPerWindow
+			// burst общих настроек относится к их лимиту
+			limits.Burst = 0
+		}
+		if rule.Burst > 0 {
+			limits.Burst = rule.Burst
+		}
+		if rule.WindowSize > 0 {
+			limits.WindowSize = rule.WindowSize
+		}
+
+		policy := Policy{
+			Name:     rule.Name,
+			Routes:   rule.Routes,
+			Exempt:   rule.Exempt,
+			Identity: base.Identity,
+			Header:   base.Header,
+			Limiter:  newRateLimiter(cache, rule.Name, limits),
+		}
+		if rule.Identity != "" {
+			policy.Identity = rule.Identity
+			policy.Header = rule.Header
+		}
+		if err := validateIdentity(&policy); err != nil {
+			return nil, err
+		}
+
+		policies.Rules = append(policies.Rules, policy)
+	}
+
+	return policies, nil
+}
+
+// validateAlgorithm не даёт опечатке в конфиге молча включить fixed_window
+func validateAlgorithm(policy, algorithm string) error {
+	switch algorithm {
+	case "", AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmGCRA:
+		return nil
+	default:
+		return fmt.Errorf("error in rate limit policy %s: unknown algorithm %q", policy, algorithm)
+	}
+}
+
+func validateIdentity(p *Policy) error {
+	switch p.Identity {
+	case "":
+		p.Identity = IdentityIP
+	case IdentityIP, IdentityOperationKey, IdentityUser:
+	case IdentityHeader:
+		if p.Header == "" {
+			return fmt.Errorf("error in rate limit policy %s: header is required for header identity", p.Name)
+		}
+	default:
+		return fmt.Errorf("error in rate limit policy %s: unknown identity %q", p.Name, p.Identity)
+	}
+	return nil
+}
+
+type userIDKey struct{}
+
+// WithID сохраняет аутентифицированного пользователя в контексте запроса
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// IDFromContext возвращает пользователя, сохранённого через WithID
+func IDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(userIDKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,108 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Caritas-Team/reviewer/internal/config"
+)
+
+func TestNewPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Config{
+		RateLimiter: config.RateLimiter{
+			Enabled:           true,
+			RequestsPerWindow: 5,
+			WindowSize:        60,
+			Policies: []config.RateLimitPolicy{
+				{Name: "probes", Routes: []string{"/health"}, Exempt: true},
+				{Name: "upload", Routes: []string{"POST /upload"}, RequestsPerWindow: 1, WindowSize: 30, Identity: IdentityOperationKey},
+			},
+		},
+	}
+
+	policies, err := NewPolicies(newMockCache(), cfg)
+	if err != nil {
+		t.Fatalf("ошибка создания политик: %v", err)
+	}
+
+	t.Run("общие настройки", func(t *testing.T) {
+		if policies.Default.Name != DefaultPolicy || policies.Default.Identity != IdentityIP {
+			t.Errorf("ожидалась политика default по IP, получил %s по %s", policies.Default.Name, policies.Default.Identity)
+		}
+		if len(policies.Rules) != 2 || !policies.Rules[0].Exempt {
+			t.Fatalf("ожидались 2 политики, первая без лимита")
+		}
+	})
+
+	t.Run("поля политики переопределяют общие", func(t *testing.T) {
+		upload := policies.Rules[1].Limiter
+		if upload.requests != 1 || upload.burst != 1 || upload.window.Seconds() != 30 {
+			t.Errorf("ожидался лимит 1 за 30 секунд, получил %d за %v", upload.requests, upload.window)
+		}
+		if policies.Rules[1].Identity != IdentityOperationKey {
+			t.Errorf("ожидалась идентичность operation_key, получил %s", policies.Rules[1].Identity)
+		}
+	})
+
+	t.Run("незаданные поля наследуются", func(t *testing.T) {
+		probes := policies.Rules[0].Limiter
+		if probes.requests != 5 || probes.window.Seconds() != 60 || !probes.enabled {
+			t.Errorf("ожидался общий лимит, получил %d за %v", probes.requests, probes.window)
+		}
+	})
+
+	t.Run("у политик отдельные счётчики", func(t *testing.T) {
+		upload := policies.Rules[1].Limiter
+		if err := upload.AllowRequest(ctx, "ip:10.0.0.1"); err != nil {
+			t.Fatalf("первый запрос должен был пройти, получил %v", err)
+		}
+		if err := upload.AllowRequest(ctx, "ip:10.0.0.1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+		if err := policies.Default.Limiter.AllowRequest(ctx, "ip:10.0.0.1"); err != nil {
+			t.Errorf("общий лимит не должен расходоваться политикой upload, получил %v", err)
+		}
+	})
+}
+
+func TestNewPolicies_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy config.RateLimitPolicy
+	}{
+		{"без имени", config.RateLimitPolicy{Routes: []string{"/get"}}},
+		{"без маршрутов", config.RateLimitPolicy{Name: "get"}},
+		{"имя общей политики", config.RateLimitPolicy{Name: DefaultPolicy, Routes: []string{"/get"}}},
+		{"неизвестная идентичность", config.RateLimitPolicy{Name: "get", Routes: []string{"/get"}, Identity: "cookie"}},
+		{"заголовок не указан", config.RateLimitPolicy{Name: "get", Routes: []string{"/get"}, Identity: IdentityHeader}},
+		{"неизвестный алгоритм", config.RateLimitPolicy{Name: "get", Routes: []string{"/get"}, Algorithm: "token_bucket"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{RateLimiter: config.RateLimiter{Policies: []config.RateLimitPolicy{tt.policy}}}
+			if _, err := NewPolicies(newMockCache(), cfg); err == nil {
+				t.Errorf("ожидалась ошибка конфига")
+			}
+		})
+	}
+}
+
+func TestNewPolicies_UnknownDefaultAlgorithm(t *testing.T) {
+	cfg := config.Config{RateLimiter: config.RateLimiter{Algorithm: "sliding-window"}}
+	if _, err := NewPolicies(newMockCache(), cfg); err == nil {
+		t.Errorf("ожидалась ошибка конфига")
+	}
+}
+
+func TestIDFromContext(t *testing.T) {
+	if _, ok := IDFromContext(context.Background()); ok {
+		t.Errorf("пользователь не должен находиться в пустом контексте")
+	}
+	if id, ok := IDFromContext(WithID(context.Background(), "u1")); !ok || id != "u1" {
+		t.Errorf("ожидался u1, получил %q", id)
+	}
+}
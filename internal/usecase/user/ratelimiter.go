@@ -3,7 +3,7 @@ package user
 import (
 	"context"
 	"errors"
-	"math"
+	"strconv"
 	"time"
 
 	"github.com/Caritas-Team/reviewer/internal/config"
@@ -12,52 +12,288 @@ import (
 
 var ErrRateLimitExceeded = errors.New("too many requests")
 
+// Алгоритмы ограничения, выбираются в rate_limiter.algorithm
+const (
+	// AlgorithmFixedWindow — счётчик на окно, сбрасывается по TTL
+	AlgorithmFixedWindow = "fixed_window"
+	// AlgorithmSlidingWindow — оценка по текущему и предыдущему окну
+	AlgorithmSlidingWindow = "sliding_window"
+	// AlgorithmGCRA — равномерный поток с допустимым всплеском burst
+	AlgorithmGCRA = "gcra"
+)
+
+// Сколько раз GCRA перечитывает состояние при конфликте версий
+const maxGCRAAttempts = 10
+
 type RateLimiter struct {
-	cache    memcached.CacheInterface
-	enabled  bool
-	window   time.Duration
-	requests int
+	cache     memcached.CacheInterface
+	scope     string
+	enabled   bool
+	algorithm string
+	window    time.Duration
+	requests  int
+	burst     int
+	now       func() time.Time
 }
 
 func NewRateLimiter(cache memcached.CacheInterface, cfg config.Config) *RateLimiter {
-	return &RateLimiter{
-		cache:    cache,
-		enabled:  cfg.RateLimiter.Enabled,
-		window:   time.Duration(cfg.RateLimiter.WindowSize) * time.Second,
-		requests: cfg.RateLimiter.RequestsPerWindow,
+	return newRateLimiter(cache, "", cfg.RateLimiter)
+}
+
+// newRateLimiter создаёт лимитер с отдельными счётчиками для scope
+func newRateLimiter(cache memcached.CacheInterface, scope string, cfg config.RateLimiter) *RateLimiter {
+	rl := &RateLimiter{
+		cache:     cache,
+		scope:     scope,
+		enabled:   cfg.Enabled,
+		algorithm: cfg.Algorithm,
+		window:    time.Duration(cfg.WindowSize) * time.Second,
+		requests:  cfg.RequestsPerWindow,
+		burst:     cfg.Burst,
+		now:       time.Now,
+	}
+	if rl.algorithm == "" {
+		rl.algorithm = AlgorithmFixedWindow
 	}
+	if rl.burst <= 0 {
+		rl.burst = rl.requests
+	}
+	return rl
+}
+
+// Decision — результат проверки лимита. Limit равен нулю, если лимит
+// не применялся: лимитер выключен или кэш недоступен.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // через сколько лимит восстановится полностью
+	RetryAfter time.Duration // через сколько пройдёт отклонённый запрос
 }
 
+// AllowRequest возвращает ErrRateLimitExceeded, если лимит исчерпан.
+// При ошибках кэша запрос разрешается: недоступный кэш не должен останавливать сервис.
 func (rl *RateLimiter) AllowRequest(ctx context.Context, userID string) error {
-	if !rl.enabled {
-		return nil
+	if !rl.Allow(ctx, userID).Allowed {
+		return ErrRateLimitExceeded
+	}
+	return nil
+}
+
+// Ping проверяет хранилище счётчиков, не расходуя лимит
+func (rl *RateLimiter) Ping() error {
+	return rl.cache.Ping()
+}
+
+// Allow проверяет лимит и возвращает решение с остатком и временем сброса
+func (rl *RateLimiter) Allow(ctx context.Context, userID string) Decision {
+	if !rl.enabled || rl.requests <= 0 || rl.window <= 0 {
+		return Decision{Allowed: true}
 	}
 
 	key := "rate_limit:" + userID
+	if rl.scope != "" {
+		key = "rate_limit:" + rl.scope + ":" + userID
+	}
 
-	newValue, err := rl.cache.Increment(ctx, key, 1)
+	var (
+		decision Decision
+		err      error
+	)
+	switch rl.algorithm {
+	case AlgorithmSlidingWindow:
+		decision, err = rl.slidingWindow(ctx, key)
+	case AlgorithmGCRA:
+		decision, err = rl.gcra(ctx, key)
+	default:
+		decision, err = rl.fixedWindow(ctx, key)
+	}
 	if err != nil {
-		return nil
+		return Decision{Allowed: true}
+	}
+	return decision
+}
+
+// fixedWindow считает запросы в окне. Ключ создаётся через Add вместе с TTL,
+// поэтому конкурирующие запросы не продлевают и не сбрасывают окно.
+// Рядом хранится время окончания окна: TTL из кэша прочитать нельзя.
+func (rl *RateLimiter) fixedWindow(ctx context.Context, key string) (Decision, error) {
+	now := rl.now()
+	resetKey := key + ":reset"
+
+	err := rl.cache.Add(ctx, key, []byte("0"), rl.window)
+	switch {
+	case err == nil:
+		end := strconv.FormatInt(now.Add(rl.window).UnixNano(), 10)
+		_ = rl.cache.Set(ctx, resetKey, []byte(end), rl.window)
+	case !errors.Is(err, memcached.ErrNotStored):
+		return Decision{}, err
 	}
 
-	// Устанавливаем TTL при первом запросе
-	if newValue == 1 {
-		err := rl.cache.Set(ctx, key, []byte("1"), rl.window)
-		if err != nil {
-			return err
+	count, err := rl.cache.Increment(ctx, key, 1)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	// Без сохранённого времени берём верхнюю границу — целое окно
+	reset := rl.window
+	if data, gerr := rl.cache.Get(ctx, resetKey); gerr == nil {
+		if end, perr := strconv.ParseInt(string(data), 10, 64); perr == nil {
+			reset = max(time.Duration(end-now.UnixNano()), 0)
 		}
 	}
 
-	if newValue > math.MaxInt {
-		// Если newValue больше максимального int - всё равно превышение
+	decision := Decision{
+		Allowed:   true,
+		Limit:     rl.requests,
+		Remaining: max(rl.requests-int(count), 0),
+		Reset:     reset,
+	}
+	if count > uint64(rl.requests) {
+		// Отклонённый запрос не расходует лимит
 		_, _ = rl.cache.Decrement(ctx, key, 1)
-		return ErrRateLimitExceeded
+		decision.Allowed = false
+		decision.RetryAfter = reset
 	}
+	return decision, nil
+}
 
-	if int(newValue) > rl.requests && rl.requests > 0 {
-		_, _ = rl.cache.Decrement(ctx, key, 1)
-		return ErrRateLimitExceeded
+// slidingWindow оценивает число запросов за последние window: текущее окно
+// целиком плюс доля предыдущего, пропорциональная ещё не прошедшей части.
+// Всплеск на границе окон так не удваивает допустимую частоту.
+// Оценка считается в целых наносекундах, умноженной на длину окна.
+func (rl *RateLimiter) slidingWindow(ctx context.Context, key string) (Decision, error) {
+	window := int64(rl.window)
+	now := rl.now().UnixNano()
+	index := now / window
+	untilEnd := window - (now - index*window)
+
+	// Счётчик окна нужен и в следующем окне как предыдущий
+	current := key + ":" + strconv.FormatInt(index, 10)
+	count, err := rl.count(ctx, current, 2*rl.window)
+	if err != nil {
+		return Decision{}, err
 	}
 
-	return nil
+	var previous uint64
+	data, err := rl.cache.Get(ctx, key+":"+strconv.FormatInt(index-1, 10))
+	switch {
+	case err == nil:
+		previous, _ = strconv.ParseUint(string(data), 10, 64)
+	case !errors.Is(err, memcached.ErrCacheMiss):
+		return Decision{}, err
+	}
+
+	// Вклад прошлого окна исчезает к концу текущего
+	limit := int64(rl.requests) * window
+	estimate := int64(previous)*untilEnd + int64(count)*window
+
+	decision := Decision{
+		Allowed:   true,
+		Limit:     rl.requests,
+		Remaining: int(max(limit-estimate, 0) / window),
+		Reset:     time.Duration(untilEnd),
+	}
+	if estimate > limit {
+		_, _ = rl.cache.Decrement(ctx, current, 1)
+		decision.Allowed = false
+		decision.Remaining = 0
+		decision.RetryAfter = rl.slidingRetryAfter(int64(previous), int64(count), untilEnd)
+	}
+	return decision, nil
+}
+
+// slidingRetryAfter — когда доля прошлого окна уменьшится настолько, что
+// отклонённый запрос уложится в лимит. Если места не хватает и без прошлого
+// окна, ждать нужно до конца текущего.
+func (rl *RateLimiter) slidingRetryAfter(previous, count, untilEnd int64) time.Duration {
+	free := int64(rl.requests) - count
+	if previous == 0 || free < 0 {
+		return time.Duration(untilEnd)
+	}
+
+	// previous * осталось + count * window <= requests * window
+	remaining := free * int64(rl.window) / previous
+	return time.Duration(max(untilEnd-remaining, 0))
+}
+
+// count атомарно увеличивает счётчик. Add создаёт ключ с TTL окна и ничего
+// не делает, если ключ уже есть, а Increment выполняется на сервере.
+func (rl *RateLimiter) count(ctx context.Context, key string, ttl time.Duration) (uint64, error) {
+	if err := rl.cache.Add(ctx, key, []byte("0"), ttl); err != nil && !errors.Is(err, memcached.ErrNotStored) {
+		return 0, err
+	}
+	return rl.cache.Increment(ctx, key, 1)
+}
+
+// gcra — Generic Cell Rate Algorithm. В кэше хранится теоретическое время
+// прихода следующего запроса (TAT): запросы идут с интервалом window/requests,
+// а до burst запросов подряд допускается опережение. Состояние меняется
+// через Add и CompareAndSwap, отклонённый запрос его не трогает.
+// Limit, как и у остальных алгоритмов, — настроенный лимит на окно, а остаток
+// считается по TAT: сколько запросов подряд пройдёт прямо сейчас.
+func (rl *RateLimiter) gcra(ctx context.Context, key string) (Decision, error) {
+	interval := rl.window / time.Duration(rl.requests)
+	tolerance := interval * time.Duration(rl.burst)
+
+	for range maxGCRAAttempts {
+		now := rl.now().UnixNano()
+
+		data, version, err := rl.cache.GetWithVersion(ctx, key)
+		exists := err == nil
+		if err != nil && !errors.Is(err, memcached.ErrCacheMiss) {
+			return Decision{}, err
+		}
+
+		tat := now
+		if exists {
+			if stored, perr := strconv.ParseInt(string(data), 10, 64); perr == nil && stored > now {
+				tat = stored
+			}
+		}
+
+		next := tat + int64(interval)
+		if next-now > int64(tolerance) {
+			return Decision{
+				Limit:      rl.requests,
+				Reset:      time.Duration(tat - now),
+				RetryAfter: time.Duration(next-now) - tolerance,
+			}, nil
+		}
+
+		// После TAT состояние ничего не ограничивает, дальше хранить его незачем
+		value := []byte(strconv.FormatInt(next, 10))
+		ttl := time.Duration(next-now) + time.Second
+		if exists {
+			err = rl.cache.CompareAndSwap(ctx, key, value, version, ttl)
+		} else {
+			err = rl.cache.Add(ctx, key, value, ttl)
+		}
+
+		switch {
+		case err == nil:
+			return Decision{
+				Allowed:   true,
+				Limit:     rl.requests,
+				Remaining: rl.gcraRemaining(next-now, interval, tolerance),
+				Reset:     time.Duration(next - now),
+			}, nil
+		case errors.Is(err, memcached.ErrCASConflict), errors.Is(err, memcached.ErrNotStored), errors.Is(err, memcached.ErrCacheMiss):
+			// Состояние изменил параллельный запрос — пересчитываем
+			continue
+		default:
+			return Decision{}, err
+		}
+	}
+
+	// Столько конфликтов подряд бывает только при шквале запросов одного
+	// клиента, поэтому запрос отклоняем, а не пропускаем
+	return Decision{Limit: rl.requests, RetryAfter: interval}, nil
+}
+
+// gcraRemaining — сколько запросов с интервалом interval ещё уложится в допуск,
+// если TAT опережает текущее время на ahead. Burst больше лимита не даёт
+// остатка больше Limit.
+func (rl *RateLimiter) gcraRemaining(ahead int64, interval, tolerance time.Duration) int {
+	return min(int((tolerance-time.Duration(ahead))/interval), rl.requests)
 }
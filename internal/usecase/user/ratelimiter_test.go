@@ -3,7 +3,11 @@ package user
 import (
 	"context"
 	"errors"
+	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -11,28 +15,39 @@ import (
 	"github.com/bradfitz/gomemcache/memcache"
 )
 
+// Ошибка сломанного кэша — сетевая, как при недоступном сервере
+var errCacheBroken = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNREFUSED}
+
 // Мок для memcached
 type mockCache struct {
+	mu         sync.Mutex
 	storage    map[string][]byte
+	versions   map[string]uint64
+	version    uint64
 	alwaysFail bool
 }
 
 func newMockCache() *mockCache {
 	return &mockCache{
-		storage: make(map[string][]byte),
+		storage:  make(map[string][]byte),
+		versions: make(map[string]uint64),
 	}
 }
 
 func newBrokenCache() *mockCache {
 	return &mockCache{
 		storage:    make(map[string][]byte),
+		versions:   make(map[string]uint64),
 		alwaysFail: true,
 	}
 }
 
 func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.alwaysFail {
-		return nil, errors.New("cache broken")
+		return nil, errCacheBroken
 	}
 
 	value, exists := m.storage[key]
@@ -43,17 +58,55 @@ func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
 }
 
 func (m *mockCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.alwaysFail {
-		return errors.New("cache broken")
+		return errCacheBroken
 	}
 
 	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+func (m *mockCache) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alwaysFail {
+		return errCacheBroken
+	}
+
+	if _, exists := m.storage[key]; exists {
+		return memcache.ErrNotStored
+	}
+	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+func (m *mockCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alwaysFail {
+		return errCacheBroken
+	}
+
+	if _, exists := m.storage[key]; !exists {
+		return memcache.ErrCacheMiss
+	}
+	delete(m.storage, key)
 	return nil
 }
 
 func (m *mockCache) Increment(ctx context.Context, key string, value uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.alwaysFail {
-		return 0, errors.New("cache broken")
+		return 0, errCacheBroken
 	}
 
 	current, exists := m.storage[key]
@@ -74,8 +127,11 @@ func (m *mockCache) Increment(ctx context.Context, key string, value uint64) (ui
 }
 
 func (m *mockCache) Decrement(ctx context.Context, key string, value uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.alwaysFail {
-		return 0, errors.New("cache broken")
+		return 0, errCacheBroken
 	}
 
 	// Получаем текущее значение
@@ -101,6 +157,56 @@ func (m *mockCache) Decrement(ctx context.Context, key string, value uint64) (ui
 	return newValue, nil
 }
 
+func (m *mockCache) GetWithVersion(ctx context.Context, key string) ([]byte, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alwaysFail {
+		return nil, 0, errCacheBroken
+	}
+
+	value, exists := m.storage[key]
+	if !exists {
+		return nil, 0, memcache.ErrCacheMiss
+	}
+	return value, m.versions[key], nil
+}
+
+func (m *mockCache) CompareAndSwap(ctx context.Context, key string, value []byte, version uint64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alwaysFail {
+		return errCacheBroken
+	}
+
+	if _, exists := m.storage[key]; !exists {
+		return memcache.ErrCacheMiss
+	}
+	if m.versions[key] != version {
+		return memcache.ErrCASConflict
+	}
+	m.storage[key] = value
+	m.bump(key)
+	return nil
+}
+
+// bump выдаёт ключу новую версию, как при любой записи в memcached
+func (m *mockCache) bump(key string) {
+	m.version++
+	m.versions[key] = m.version
+}
+
+func (m *mockCache) Ping() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alwaysFail {
+		return errCacheBroken
+	}
+	return nil
+}
+
 func (m *mockCache) Close() error {
 	return nil
 }
@@ -220,3 +326,286 @@ func TestRateLimiter_CacheError(t *testing.T) {
 		t.Errorf("при ошибках кэша должен разрешать запрос")
 	}
 }
+
+func newTestLimiter(cache *mockCache, algorithm string, requests, burst int) (*RateLimiter, *time.Time) {
+	limiter := NewRateLimiter(cache, config.Config{
+		RateLimiter: config.RateLimiter{
+			Enabled:           true,
+			Algorithm:         algorithm,
+			RequestsPerWindow: requests,
+			Burst:             burst,
+			WindowSize:        60,
+		},
+	})
+
+	// Начало окна, чтобы тесты не зависели от текущего времени
+	now := time.Unix(1_700_000_040, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiter_SlidingWindow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("лимит в пределах окна", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmSlidingWindow, 10, 0)
+
+		for i := 0; i < 10; i++ {
+			if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+				t.Fatalf("запрос %d должен был пройти, получил %v", i+1, err)
+			}
+		}
+		if err := limiter.AllowRequest(ctx, "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+	})
+
+	t.Run("всплеск на границе окон не удваивает лимит", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmSlidingWindow, 10, 0)
+
+		// 10 запросов в конце окна
+		*now = now.Add(59 * time.Second)
+		for i := 0; i < 10; i++ {
+			if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+				t.Fatalf("запрос %d должен был пройти, получил %v", i+1, err)
+			}
+		}
+
+		// Через 2 секунды, уже в новом окне, прошлое окно учитывается почти целиком
+		*now = now.Add(2 * time.Second)
+		if err := limiter.AllowRequest(ctx, "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+
+		// К середине окна вес прошлого окна падает вдвое
+		*now = now.Add(29 * time.Second)
+		allowed := 0
+		for i := 0; i < 10; i++ {
+			if limiter.AllowRequest(ctx, "user1") == nil {
+				allowed++
+			}
+		}
+		if allowed != 5 {
+			t.Errorf("ожидалось 5 разрешённых запросов, получил %d", allowed)
+		}
+	})
+
+	t.Run("отклонённые запросы не расходуют лимит", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmSlidingWindow, 2, 0)
+
+		for i := 0; i < 5; i++ {
+			_ = limiter.AllowRequest(ctx, "user1")
+		}
+
+		// Через два окна ограничений нет
+		*now = now.Add(2 * time.Minute)
+		for i := 0; i < 2; i++ {
+			if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+				t.Errorf("запрос %d должен был пройти, получил %v", i+1, err)
+			}
+		}
+	})
+}
+
+func TestRateLimiter_GCRA(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("всплеск ограничен burst", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmGCRA, 60, 5)
+
+		for i := 0; i < 5; i++ {
+			if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+				t.Fatalf("запрос %d должен был пройти, получил %v", i+1, err)
+			}
+		}
+		if err := limiter.AllowRequest(ctx, "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+	})
+
+	t.Run("лимит восстанавливается равномерно", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmGCRA, 60, 5)
+
+		for i := 0; i < 5; i++ {
+			_ = limiter.AllowRequest(ctx, "user1")
+		}
+
+		// 60 запросов в минуту — один запрос в секунду
+		*now = now.Add(time.Second)
+		if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+			t.Errorf("через секунду запрос должен был пройти, получил %v", err)
+		}
+		if err := limiter.AllowRequest(ctx, "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+	})
+
+	t.Run("burst по умолчанию равен лимиту", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmGCRA, 3, 0)
+
+		for i := 0; i < 3; i++ {
+			if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+				t.Fatalf("запрос %d должен был пройти, получил %v", i+1, err)
+			}
+		}
+		if err := limiter.AllowRequest(ctx, "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+	})
+
+	t.Run("ошибка кэша не блокирует запросы", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newBrokenCache(), AlgorithmGCRA, 1, 0)
+
+		if err := limiter.AllowRequest(ctx, "user1"); err != nil {
+			t.Errorf("при ошибках кэша должен разрешать запрос, получил %v", err)
+		}
+	})
+}
+
+// concurrentRequests выполняет n запросов параллельно и возвращает число разрешённых
+func concurrentRequests(limiter *RateLimiter, n int) int64 {
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int64
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.AllowRequest(context.Background(), "user1") == nil {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	return allowed.Load()
+}
+
+func TestRateLimiter_Concurrent(t *testing.T) {
+	t.Run("fixed_window", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmFixedWindow, 10, 0)
+		if allowed := concurrentRequests(limiter, 100); allowed != 10 {
+			t.Errorf("ожидалось 10 разрешённых запросов, получил %d", allowed)
+		}
+	})
+
+	t.Run("sliding_window", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmSlidingWindow, 10, 0)
+		if allowed := concurrentRequests(limiter, 100); allowed != 10 {
+			t.Errorf("ожидалось 10 разрешённых запросов, получил %d", allowed)
+		}
+	})
+
+	t.Run("gcra", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmGCRA, 60, 10)
+
+		// Часть запросов может проиграть гонку за версию, но лишних пройти не должно
+		allowed := concurrentRequests(limiter, 100)
+		if allowed == 0 || allowed > 10 {
+			t.Errorf("ожидалось от 1 до 10 разрешённых запросов, получил %d", allowed)
+		}
+
+		// Оставшийся запас расходуется последовательно
+		for i := allowed; i < 10; i++ {
+			if err := limiter.AllowRequest(context.Background(), "user1"); err != nil {
+				t.Fatalf("запрос должен был пройти, получил %v", err)
+			}
+		}
+		if err := limiter.AllowRequest(context.Background(), "user1"); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("ожидалась ошибка лимита, получил %v", err)
+		}
+	})
+}
+
+func TestRateLimiter_Decision(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fixed_window", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmFixedWindow, 2, 0)
+
+		decision := limiter.Allow(ctx, "user1")
+		if !decision.Allowed || decision.Limit != 2 || decision.Remaining != 1 || decision.Reset != time.Minute {
+			t.Errorf("ожидался остаток 1 и сброс через минуту, получил %+v", decision)
+		}
+
+		*now = now.Add(20 * time.Second)
+		_ = limiter.Allow(ctx, "user1")
+		decision = limiter.Allow(ctx, "user1")
+		if decision.Allowed || decision.Remaining != 0 || decision.RetryAfter != 40*time.Second {
+			t.Errorf("ожидался отказ с повтором через 40 секунд, получил %+v", decision)
+		}
+	})
+
+	t.Run("sliding_window", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmSlidingWindow, 10, 0)
+
+		for i := 0; i < 10; i++ {
+			_ = limiter.Allow(ctx, "user1")
+		}
+
+		// В середине следующего окна от прошлого осталась половина
+		*now = now.Add(90 * time.Second)
+		for i := 0; i < 5; i++ {
+			_ = limiter.Allow(ctx, "user1")
+		}
+		decision := limiter.Allow(ctx, "user1")
+		if decision.Allowed || decision.Reset != 30*time.Second {
+			t.Fatalf("ожидался отказ со сбросом через 30 секунд, получил %+v", decision)
+		}
+		// 10 * (1 - e) + 5 + 1 <= 10 при e >= 0.6, то есть через 6 секунд
+		if decision.RetryAfter != 6*time.Second {
+			t.Errorf("ожидался повтор через 6 секунд, получил %v", decision.RetryAfter)
+		}
+
+		*now = now.Add(decision.RetryAfter)
+		if decision = limiter.Allow(ctx, "user1"); !decision.Allowed {
+			t.Errorf("после RetryAfter запрос должен был пройти, получил %+v", decision)
+		}
+	})
+
+	t.Run("gcra", func(t *testing.T) {
+		limiter, now := newTestLimiter(newMockCache(), AlgorithmGCRA, 60, 3)
+
+		decision := limiter.Allow(ctx, "user1")
+		// Limit — лимит на окно, а не burst
+		if !decision.Allowed || decision.Limit != 60 || decision.Remaining != 2 || decision.Reset != time.Second {
+			t.Errorf("ожидался лимит 60, остаток 2 и сброс через секунду, получил %+v", decision)
+		}
+
+		_ = limiter.Allow(ctx, "user1")
+		_ = limiter.Allow(ctx, "user1")
+		decision = limiter.Allow(ctx, "user1")
+		if decision.Allowed || decision.Limit != 60 || decision.RetryAfter != time.Second || decision.Reset != 3*time.Second {
+			t.Fatalf("ожидался отказ с повтором через секунду, получил %+v", decision)
+		}
+
+		*now = now.Add(decision.RetryAfter)
+		if decision = limiter.Allow(ctx, "user1"); !decision.Allowed || decision.Remaining != 0 {
+			t.Errorf("после RetryAfter запрос должен был пройти, получил %+v", decision)
+		}
+	})
+
+	t.Run("gcra с burst больше лимита", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newMockCache(), AlgorithmGCRA, 5, 20)
+
+		decision := limiter.Allow(ctx, "user1")
+		if !decision.Allowed || decision.Limit != 5 || decision.Remaining != 5 {
+			t.Errorf("ожидался остаток не больше лимита 5, получил %+v", decision)
+		}
+
+		for i := 0; i < 15; i++ {
+			_ = limiter.Allow(ctx, "user1")
+		}
+		if decision = limiter.Allow(ctx, "user1"); !decision.Allowed || decision.Remaining != 3 {
+			t.Errorf("ожидался остаток 3, получил %+v", decision)
+		}
+	})
+
+	t.Run("ошибка кэша", func(t *testing.T) {
+		limiter, _ := newTestLimiter(newBrokenCache(), AlgorithmSlidingWindow, 1, 0)
+
+		if decision := limiter.Allow(ctx, "user1"); !decision.Allowed || decision.Limit != 0 {
+			t.Errorf("ожидалось разрешение без лимита, получил %+v", decision)
+		}
+	})
+}